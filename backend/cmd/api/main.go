@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"log"
 	"lomi-backend/config"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/handlers"
 	"lomi-backend/internal/routes"
 	"lomi-backend/internal/services"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -17,6 +23,10 @@ func main() {
 	// 1. Load Configuration
 	cfg := config.LoadConfig()
 
+	if cfg.AdminUserIDs == "" {
+		log.Printf("⚠️ ADMIN_USER_IDS is not set: every /admin route will return 403")
+	}
+
 	// 2. Connect to Database
 	database.ConnectDB(cfg)
 
@@ -34,6 +44,18 @@ func main() {
 		cfg.FirebaseServerKey,
 	)
 
+	// Initialize Discovery Ranking (weights from RANK_WEIGHT_*)
+	services.InitRankingService(services.RankingWeightsFromConfig(cfg))
+
+	// Initialize WebSocket Hub (fans out through Redis pub/sub across API instances)
+	handlers.InitWebSocketHub()
+
+	// Expire unanswered matches and send reminders (MATCH_EXPIRY_*)
+	go services.StartMatchExpiryWorker()
+
+	// Buna invite reminders and post-date feedback prompts (BUNA_*)
+	go services.StartBunaWorker()
+
 	// 5. Initialize Fiber App
 	app := fiber.New(fiber.Config{
 		AppName:      cfg.AppName,
@@ -45,19 +67,39 @@ func main() {
 	app.Use(logger.New())  // Request logging
 	app.Use(recover.New()) // Panic recovery
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*", // Allow all for dev, restrict in prod
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-		AllowMethods: "GET, POST, HEAD, PUT, DELETE, PATCH",
+		AllowOrigins:  "*", // Allow all for dev, restrict in prod
+		AllowHeaders:  "Origin, Content-Type, Accept, Authorization, Idempotency-Key",
+		AllowMethods:  "GET, POST, HEAD, PUT, DELETE, PATCH",
+		ExposeHeaders: "Idempotent-Replayed",
 	}))
 
 	// 7. Routes
 	routes.SetupRoutes(app)
 
 	// 8. Start Server
-	log.Printf("🚀 Server starting on port %s", cfg.AppPort)
-	if err := app.Listen(":" + cfg.AppPort); err != nil {
-		log.Fatal("Server failed to start: ", err)
+	go func() {
+		log.Printf("🚀 Server starting on port %s", cfg.AppPort)
+		if err := app.Listen(":" + cfg.AppPort); err != nil {
+			log.Fatal("Server failed to start: ", err)
+		}
+	}()
+
+	// 9. Graceful Shutdown: close WebSockets first so clients reconnect elsewhere, then HTTP
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	log.Printf("🛑 Shutting down...")
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := handlers.ShutdownWebSocketHub(ctx); err != nil {
+		log.Printf("⚠️ WebSocket hub did not drain: %v", err)
+	}
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Printf("⚠️ Server shutdown error: %v", err)
 	}
+	log.Printf("✅ Server stopped")
 }
 
 func setupRoutes(app *fiber.App) {
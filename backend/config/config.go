@@ -36,6 +36,7 @@ type Config struct {
 	S3BucketVideos string
 	S3BucketGifts  string
 	S3BucketVerify string
+	S3BucketChat   string // Private: chat media, served through presigned URLs only
 
 	// JWT
 	JWTSecret        string
@@ -45,6 +46,9 @@ type Config struct {
 	// Telegram
 	TelegramBotToken string
 
+	// Admin API: comma-separated user IDs allowed on /admin routes (none if empty)
+	AdminUserIDs string
+
 	// Google OAuth
 	GoogleClientID string
 
@@ -52,6 +56,35 @@ type Config struct {
 	OneSignalAppID    string
 	OneSignalAPIKey   string
 	FirebaseServerKey string
+
+	// Discovery
+	DiscoveryMaxDistanceKm int  // Default radius when the user has no max_distance preference
+	GeoUsePostGIS          bool // Use PostGIS ST_DWithin instead of bounding box + Haversine
+
+	// Discovery ranking weights (see services.RankingWeights)
+	RankWeightDistance         float64
+	RankWeightSharedInterests  float64
+	RankWeightSharedLanguages  float64
+	RankWeightRelationshipGoal float64
+	RankWeightReligion         float64
+	RankWeightActivity         float64
+	RankWeightVerified         float64
+	RankWeightLikeRate         float64
+
+	// Match expiry (matches with no first message)
+	MatchExpiryHours         int // Hours until an unanswered match expires
+	MatchReminderHours       int // Send a reminder when this many hours are left
+	MatchExtendHours         int // Hours added by a paid extension
+	MatchExtendCost          int // Coins per extension
+	MatchExpiryCheckInterval int // Seconds between expiry job runs
+
+	// Buna (coffee date) invites
+	BunaReminderMinutes    int // Remind both users this long before an accepted buna
+	BunaFeedbackDelayHours int // Ask "how was it?" this long after the buna time
+	BunaCheckInterval      int // Seconds between reminder/feedback job runs
+
+	// Chat content screening: per-rule action overrides, "rule=action,..." (see services.ScreenMessage)
+	MessageScreeningActions string
 }
 
 var Cfg *Config
@@ -86,6 +119,7 @@ func LoadConfig() *Config {
 		S3BucketVideos: getEnv("S3_BUCKET_VIDEOS", "lomi-videos"),
 		S3BucketGifts:  getEnv("S3_BUCKET_GIFTS", "lomi-gifts"),
 		S3BucketVerify: getEnv("S3_BUCKET_VERIFICATIONS", "lomi-verifications"),
+		S3BucketChat:   getEnv("S3_BUCKET_CHAT", "lomi-chat"),
 
 		JWTSecret:        getEnv("JWT_SECRET", "secret"),
 		JWTAccessExpiry:  getEnv("JWT_ACCESS_EXPIRY", "24h"),
@@ -93,11 +127,37 @@ func LoadConfig() *Config {
 
 		TelegramBotToken: getEnv("TELEGRAM_BOT_TOKEN", ""),
 
+		AdminUserIDs: getEnv("ADMIN_USER_IDS", ""),
+
 		GoogleClientID: getEnv("GOOGLE_CLIENT_ID", ""),
 
 		OneSignalAppID:    getEnv("ONESIGNAL_APP_ID", ""),
 		OneSignalAPIKey:   getEnv("ONESIGNAL_API_KEY", ""),
 		FirebaseServerKey: getEnv("FIREBASE_SERVER_KEY", ""),
+
+		DiscoveryMaxDistanceKm: getEnvAsInt("DISCOVERY_MAX_DISTANCE_KM", 50),
+		GeoUsePostGIS:          getEnvAsBool("GEO_USE_POSTGIS", false),
+
+		RankWeightDistance:         getEnvAsFloat("RANK_WEIGHT_DISTANCE", 3.0),
+		RankWeightSharedInterests:  getEnvAsFloat("RANK_WEIGHT_SHARED_INTERESTS", 2.0),
+		RankWeightSharedLanguages:  getEnvAsFloat("RANK_WEIGHT_SHARED_LANGUAGES", 1.0),
+		RankWeightRelationshipGoal: getEnvAsFloat("RANK_WEIGHT_RELATIONSHIP_GOAL", 1.5),
+		RankWeightReligion:         getEnvAsFloat("RANK_WEIGHT_RELIGION", 1.0),
+		RankWeightActivity:         getEnvAsFloat("RANK_WEIGHT_ACTIVITY", 2.0),
+		RankWeightVerified:         getEnvAsFloat("RANK_WEIGHT_VERIFIED", 0.5),
+		RankWeightLikeRate:         getEnvAsFloat("RANK_WEIGHT_LIKE_RATE", 1.0),
+
+		MatchExpiryHours:         getEnvAsInt("MATCH_EXPIRY_HOURS", 72),
+		MatchReminderHours:       getEnvAsInt("MATCH_REMINDER_HOURS", 24),
+		MatchExtendHours:         getEnvAsInt("MATCH_EXTEND_HOURS", 24),
+		MatchExtendCost:          getEnvAsInt("MATCH_EXTEND_COST", 49),
+		MatchExpiryCheckInterval: getEnvAsInt("MATCH_EXPIRY_CHECK_INTERVAL", 300),
+
+		BunaReminderMinutes:    getEnvAsInt("BUNA_REMINDER_MINUTES", 120),
+		BunaFeedbackDelayHours: getEnvAsInt("BUNA_FEEDBACK_DELAY_HOURS", 3),
+		BunaCheckInterval:      getEnvAsInt("BUNA_CHECK_INTERVAL", 300),
+
+		MessageScreeningActions: getEnv("MESSAGE_SCREENING_ACTIONS", ""),
 	}
 	return Cfg
 }
@@ -117,6 +177,14 @@ func getEnvAsInt(key string, fallback int) int {
 	return fallback
 }
 
+func getEnvAsFloat(key string, fallback float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return fallback
+}
+
 func getEnvAsBool(key string, fallback bool) bool {
 	valueStr := getEnv(key, "")
 	if value, err := strconv.ParseBool(valueStr); err == nil {
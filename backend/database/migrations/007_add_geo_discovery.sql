@@ -0,0 +1,18 @@
+-- Migration: Distance-aware discovery
+-- Discovery filters by users.latitude/longitude with a bounding box + Haversine check.
+-- The composite index below serves the bounding-box prefilter.
+
+CREATE INDEX IF NOT EXISTS idx_users_location ON users(latitude, longitude)
+WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
+
+-- Optional PostGIS path (enable with GEO_USE_POSTGIS=true).
+-- Only installed when the postgis package is available on the server.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_available_extensions WHERE name = 'postgis') THEN
+        CREATE EXTENSION IF NOT EXISTS postgis;
+        EXECUTE 'CREATE INDEX IF NOT EXISTS idx_users_geography ON users
+                 USING GIST (geography(ST_MakePoint(longitude::float8, latitude::float8)))
+                 WHERE latitude IS NOT NULL AND longitude IS NOT NULL';
+    END IF;
+END $$;
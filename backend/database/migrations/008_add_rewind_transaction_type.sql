@@ -0,0 +1,4 @@
+-- Rewind (undo last swipe) Migration
+-- Adds the 'rewind' coin transaction type
+
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'rewind';
@@ -0,0 +1,11 @@
+-- Super Like Migration
+-- Adds daily free super like tracking and the 'super_like' coin transaction type
+
+ALTER TABLE users
+ADD COLUMN IF NOT EXISTS daily_super_likes_used INTEGER DEFAULT 0,
+ADD COLUMN IF NOT EXISTS last_super_like_date DATE;
+
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'super_like';
+
+COMMENT ON COLUMN users.daily_super_likes_used IS 'Free super likes used today (resets at midnight Addis time)';
+COMMENT ON COLUMN users.last_super_like_date IS 'Last date when user used a free super like (for daily reset logic)';
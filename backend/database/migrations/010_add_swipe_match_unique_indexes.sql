@@ -0,0 +1,43 @@
+-- Race-free Swipes & Matches Migration
+-- SwipeAction relies on unique indexes instead of read-then-write checks:
+--   swipes(swiper_id, swiped_id)  -> INSERT ... ON CONFLICT DO NOTHING ("Already swiped")
+--   matches(user1_id, user2_id)   -> match upsert on the ordered pair
+-- Databases created from schema.sql already have these as UNIQUE constraints.
+
+-- Remove duplicate swipes, keeping the most recent per pair
+DELETE FROM swipes s
+USING swipes newer
+WHERE s.swiper_id = newer.swiper_id
+  AND s.swiped_id = newer.swiped_id
+  AND (s.created_at, s.id) < (newer.created_at, newer.id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_swipes_swiper_swiped_unique ON swipes(swiper_id, swiped_id);
+
+-- Deduplicate matches before normalising pair ordering (user1_id < user2_id): swapping
+-- a reversed duplicate first would violate schema.sql's UNIQUE(user1_id, user2_id).
+-- A/B and B/A are the same pair. Messages move onto the oldest match for the pair.
+WITH ranked AS (
+    SELECT id,
+           FIRST_VALUE(id) OVER (
+               PARTITION BY LEAST(user1_id, user2_id), GREATEST(user1_id, user2_id)
+               ORDER BY created_at, id
+           ) AS keep_id
+    FROM matches
+)
+UPDATE messages m
+SET match_id = ranked.keep_id
+FROM ranked
+WHERE m.match_id = ranked.id
+  AND ranked.id <> ranked.keep_id;
+
+DELETE FROM matches m
+USING matches older
+WHERE LEAST(m.user1_id, m.user2_id) = LEAST(older.user1_id, older.user2_id)
+  AND GREATEST(m.user1_id, m.user2_id) = GREATEST(older.user1_id, older.user2_id)
+  AND (older.created_at, older.id) < (m.created_at, m.id);
+
+UPDATE matches
+SET user1_id = user2_id, user2_id = user1_id
+WHERE user1_id > user2_id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_matches_user_pair_unique ON matches(user1_id, user2_id);
@@ -0,0 +1,50 @@
+-- Match Lifecycle Migration
+-- Adds explicit match states, rematch epochs and a match history table
+
+-- Match state and epoch
+ALTER TABLE matches
+ADD COLUMN IF NOT EXISTS status VARCHAR(20) DEFAULT 'active',
+ADD COLUMN IF NOT EXISTS epoch INTEGER DEFAULT 1,
+ADD COLUMN IF NOT EXISTS epoch_started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+-- Backfill from the old is_active flag
+UPDATE matches SET status = 'unmatched' WHERE is_active = FALSE AND status = 'active';
+UPDATE matches SET epoch_started_at = created_at WHERE epoch_started_at > created_at;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint WHERE conname = 'matches_status_check'
+    ) THEN
+        ALTER TABLE matches ADD CONSTRAINT matches_status_check
+            CHECK (status IN ('active', 'unmatched', 'expired', 'blocked'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_matches_status ON matches(status);
+
+-- Messages belong to the epoch they were sent in
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS epoch INTEGER DEFAULT 1;
+
+CREATE INDEX IF NOT EXISTS idx_messages_match_epoch ON messages(match_id, epoch, created_at);
+
+-- Match history (matched, unmatched, rematched, expired, blocked)
+CREATE TABLE IF NOT EXISTS match_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    match_id UUID NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    event_type VARCHAR(20) NOT NULL,
+    epoch INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_match_events_match_id ON match_events(match_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_match_events_actor ON match_events(actor_id, event_type, created_at);
+
+-- Seed history for existing unmatches so they show up in GET /matches/unmatched
+INSERT INTO match_events (match_id, actor_id, event_type, epoch, created_at)
+SELECT m.id, m.unmatched_by, 'unmatched', m.epoch, COALESCE(m.unmatched_at, NOW())
+FROM matches m
+WHERE m.status = 'unmatched'
+  AND NOT EXISTS (SELECT 1 FROM match_events e WHERE e.match_id = m.id);
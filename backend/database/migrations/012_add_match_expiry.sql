@@ -0,0 +1,27 @@
+-- Match Expiry Migration
+-- Unanswered matches expire (MATCH_EXPIRY_HOURS); users can pay to extend
+
+ALTER TABLE matches
+ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN IF NOT EXISTS first_message_at TIMESTAMP WITH TIME ZONE,
+ADD COLUMN IF NOT EXISTS expiry_reminder_sent BOOLEAN DEFAULT FALSE,
+ADD COLUMN IF NOT EXISTS extension_count INTEGER DEFAULT 0;
+
+-- Backfill first_message_at for the current epoch so existing conversations never expire.
+-- Existing silent matches keep expires_at NULL (no expiry); only new matches expire.
+UPDATE matches m
+SET first_message_at = sub.first_at
+FROM (
+    SELECT match_id, epoch, MIN(created_at) AS first_at
+    FROM messages
+    GROUP BY match_id, epoch
+) sub
+WHERE sub.match_id = m.id
+  AND sub.epoch = m.epoch
+  AND m.first_message_at IS NULL;
+
+-- Expiry job scans active, unanswered matches by expiry time
+CREATE INDEX IF NOT EXISTS idx_matches_pending_expiry ON matches(expires_at)
+    WHERE status = 'active' AND first_message_at IS NULL;
+
+ALTER TYPE transaction_type ADD VALUE IF NOT EXISTS 'match_extend';
@@ -0,0 +1,30 @@
+-- Message Sequence Migration
+-- Per-match message sequence numbers for offline sync, and client message IDs for safe retries
+
+ALTER TABLE matches
+ADD COLUMN IF NOT EXISTS last_seq BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS seq BIGINT,
+ADD COLUMN IF NOT EXISTS client_message_id VARCHAR(64);
+
+-- Number existing messages in send order
+UPDATE messages m
+SET seq = n.seq
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY match_id ORDER BY created_at, id) AS seq
+    FROM messages
+) n
+WHERE m.id = n.id AND m.seq IS NULL;
+
+UPDATE matches mt
+SET last_seq = s.max_seq
+FROM (SELECT match_id, MAX(seq) AS max_seq FROM messages GROUP BY match_id) s
+WHERE mt.id = s.match_id AND mt.last_seq < s.max_seq;
+
+ALTER TABLE messages ALTER COLUMN seq SET DEFAULT 0;
+ALTER TABLE messages ALTER COLUMN seq SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_match_seq ON messages(match_id, seq);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_sender_client_id
+    ON messages(sender_id, client_message_id) WHERE client_message_id IS NOT NULL;
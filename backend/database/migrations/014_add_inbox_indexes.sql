@@ -0,0 +1,8 @@
+-- Inbox Indexes Migration
+-- Supports the aggregated inbox query (unread counts and first photo per conversation)
+
+CREATE INDEX IF NOT EXISTS idx_messages_unread
+    ON messages(match_id, epoch, receiver_id) WHERE is_read = FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_media_user_photo_order
+    ON media(user_id, display_order) WHERE media_type = 'photo' AND is_approved = TRUE;
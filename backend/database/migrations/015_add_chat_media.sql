@@ -0,0 +1,19 @@
+-- Chat Media Migration
+-- Uploads to the private chat bucket (photos, videos, voice notes), one row per file
+
+CREATE TABLE IF NOT EXISTS chat_media (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    match_id UUID NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    uploader_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    media_type VARCHAR(20) NOT NULL CHECK (media_type IN ('photo', 'video', 'voice')),
+    object_key TEXT NOT NULL UNIQUE,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    duration_seconds INTEGER DEFAULT 0,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'attached')),
+    message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_media_match ON chat_media(match_id);
+CREATE INDEX IF NOT EXISTS idx_chat_media_uploader ON chat_media(uploader_id, status);
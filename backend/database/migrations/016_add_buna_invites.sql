@@ -0,0 +1,8 @@
+-- Buna Invites Migration
+-- Buna (coffee date) invites are buna_invite messages; their state lives in messages.metadata
+-- (status, scheduled_at, reminder_sent_at, feedback_prompt_sent_at, feedback)
+
+-- Reminder and feedback jobs scan accepted invites
+CREATE INDEX IF NOT EXISTS idx_messages_buna_status
+    ON messages ((metadata->>'status'))
+    WHERE message_type = 'buna_invite';
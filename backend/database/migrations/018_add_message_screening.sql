@@ -0,0 +1,26 @@
+-- Message Screening Migration
+-- The chat content filter can hold messages for review; every match is recorded for admins
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS held BOOLEAN DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS flagged_messages (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+    match_id UUID NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    receiver_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    message_type VARCHAR(20) NOT NULL,
+    content TEXT,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('warn', 'hold', 'block')),
+    rules JSONB DEFAULT '[]',
+    matches JSONB DEFAULT '[]',
+    is_reviewed BOOLEAN DEFAULT FALSE,
+    reviewed_by UUID REFERENCES users(id),
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    decision VARCHAR(10) CHECK (decision IN ('release', 'reject', 'dismiss')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_flagged_messages_queue ON flagged_messages(is_reviewed, action, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_flagged_messages_sender ON flagged_messages(sender_id);
+CREATE INDEX IF NOT EXISTS idx_flagged_messages_message ON flagged_messages(message_id);
@@ -0,0 +1,22 @@
+-- Message Search Migration
+-- Full-text search over chat messages and chat transcripts attached to reports
+
+-- The 'simple' configuration does no stemming, so it works for Amharic as well as English.
+-- Ethiopic punctuation (፡ word space, ። full stop, ፣ comma, ...) is turned into spaces first
+-- so that words separated by it become separate tokens.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS content_tsv TSVECTOR
+    GENERATED ALWAYS AS (
+        to_tsvector('simple'::regconfig, regexp_replace(coalesce(content, ''), '[፡።፣፤፥፦፧፨]', ' ', 'g'))
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_content_tsv ON messages USING GIN (content_tsv);
+
+-- Amharic words take prefixes and suffixes that the simple parser keeps attached,
+-- so Ethiopic queries also fall back to a substring match served by a trigram index
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_messages_content_trgm ON messages USING GIN (content gin_trgm_ops);
+
+-- Object key of the transcript snapshot in the chat bucket
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS transcript_key TEXT;
+ALTER TABLE reports ADD COLUMN IF NOT EXISTS match_id UUID REFERENCES matches(id) ON DELETE SET NULL;
@@ -0,0 +1,49 @@
+-- Profile Prompts Migration
+-- Admin-managed icebreaker questions (English and Amharic), users' answers on their profile,
+-- and likes that reply to a prompt
+
+CREATE TABLE IF NOT EXISTS prompts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    category VARCHAR(50),
+    text_en TEXT NOT NULL,
+    text_am TEXT NOT NULL,
+    is_active BOOLEAN DEFAULT TRUE,
+    display_order INTEGER DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_prompts_active ON prompts(is_active, display_order);
+
+CREATE TABLE IF NOT EXISTS profile_prompts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    prompt_id UUID NOT NULL REFERENCES prompts(id) ON DELETE CASCADE,
+    answer TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_profile_prompts_user_prompt ON profile_prompts(user_id, prompt_id);
+CREATE INDEX IF NOT EXISTS idx_profile_prompts_user ON profile_prompts(user_id, position);
+
+ALTER TABLE swipes ADD COLUMN IF NOT EXISTS prompt_answer_id UUID REFERENCES profile_prompts(id) ON DELETE SET NULL;
+ALTER TABLE swipes ADD COLUMN IF NOT EXISTS prompt_comment TEXT;
+
+ALTER TYPE message_type ADD VALUE IF NOT EXISTS 'prompt_reply';
+
+-- Starter catalogue, only on a fresh table
+INSERT INTO prompts (category, text_en, text_am, display_order)
+SELECT v.category, v.text_en, v.text_am, v.display_order
+FROM (VALUES
+    ('about_me', 'A fun fact about me is...', 'ስለ እኔ አስገራሚ እውነታ...', 1),
+    ('about_me', 'My ideal Sunday is...', 'የእኔ ምርጥ እሁድ...', 2),
+    ('about_me', 'I get way too excited about...', 'በጣም የሚያስደስተኝ ነገር...', 3),
+    ('food', 'My go-to Ethiopian dish is...', 'በጣም የምወደው የኢትዮጵያ ምግብ...', 4),
+    ('dating', 'My favourite buna spot is...', 'የምወደው የቡና ቦታ...', 5),
+    ('dating', 'The way to my heart is...', 'ወደ ልቤ የሚያደርሰው መንገድ...', 6),
+    ('dating', 'I''m looking for someone who...', 'የምፈልገው ሰው...', 7),
+    ('dating', 'Together we could...', 'አብረን ልንሞክረው የምንችለው...', 8)
+) AS v(category, text_en, text_am, display_order)
+WHERE NOT EXISTS (SELECT 1 FROM prompts);
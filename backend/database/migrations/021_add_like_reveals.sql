@@ -0,0 +1,20 @@
+-- Like Reveals Migration
+-- Ledger of likers a user unlocked on "Who Likes You", and blurred photo previews
+
+CREATE TABLE IF NOT EXISTS like_reveals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    liker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source VARCHAR(20) NOT NULL CHECK (source IN ('free', 'single', 'all')),
+    coin_transaction_id UUID REFERENCES coin_transactions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_like_reveals_user_liker ON like_reveals(user_id, liker_id);
+
+-- Inbound likes of a user, newest first
+CREATE INDEX IF NOT EXISTS idx_swipes_inbound_likes ON swipes(swiped_id, created_at DESC)
+    WHERE action IN ('like', 'super_like');
+
+-- Tiny blurred rendition of a photo (data URI) shown for unrevealed likers, filled on first use
+ALTER TABLE media ADD COLUMN IF NOT EXISTS blur_preview TEXT;
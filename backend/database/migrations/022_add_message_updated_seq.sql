@@ -0,0 +1,11 @@
+-- Message Change Cursor Migration
+-- Edits, deletes for everyone, reactions, buna updates and moderator releases take the
+-- match's next sequence number as the message's updated_seq, so offline sync (which
+-- returns updated_seq > cursor) picks up changes to messages the client already has
+
+ALTER TABLE messages
+ADD COLUMN IF NOT EXISTS updated_seq BIGINT NOT NULL DEFAULT 0;
+
+UPDATE messages SET updated_seq = seq WHERE updated_seq < seq;
+
+CREATE INDEX IF NOT EXISTS idx_messages_match_updated_seq ON messages(match_id, updated_seq);
@@ -0,0 +1,7 @@
+-- Rewound Match Status Migration
+-- A rewind that undoes the like completing a match ends it as 'rewound': only the
+-- rewinder's swipe is removed and, unlike an unmatch, no rematch cooldown applies
+
+ALTER TABLE matches DROP CONSTRAINT IF EXISTS matches_status_check;
+ALTER TABLE matches ADD CONSTRAINT matches_status_check
+    CHECK (status IN ('active', 'unmatched', 'expired', 'blocked', 'rewound'));
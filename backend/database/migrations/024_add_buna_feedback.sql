@@ -0,0 +1,41 @@
+-- Buna Feedback Migration
+-- Post-date feedback (rating, felt_safe, comment, report) moves out of the invite message's
+-- metadata, which both participants can read, into its own table
+
+CREATE TABLE IF NOT EXISTS buna_feedback (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    message_id UUID NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    match_id UUID NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    rating INTEGER DEFAULT 0,
+    showed_up BOOLEAN,
+    felt_safe BOOLEAN,
+    comment TEXT,
+    report_id UUID REFERENCES reports(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_buna_feedback_message_user ON buna_feedback(message_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_buna_feedback_match_id ON buna_feedback(match_id);
+
+-- Move feedback already stored in invites
+INSERT INTO buna_feedback (message_id, user_id, match_id, rating, showed_up, felt_safe, comment, report_id, created_at)
+SELECT m.id,
+       f.key::uuid,
+       m.match_id,
+       COALESCE((f.value->>'rating')::int, 0),
+       (f.value->>'showed_up')::boolean,
+       (f.value->>'felt_safe')::boolean,
+       f.value->>'comment',
+       (f.value->>'report_id')::uuid,
+       COALESCE((f.value->>'created_at')::timestamptz, NOW())
+FROM messages m
+CROSS JOIN LATERAL jsonb_each(m.metadata->'feedback') f
+WHERE m.message_type = 'buna_invite'
+  AND jsonb_typeof(m.metadata->'feedback') = 'object'
+ON CONFLICT (message_id, user_id) DO NOTHING;
+
+UPDATE messages
+SET metadata = metadata - 'feedback'
+WHERE message_type = 'buna_invite'
+  AND metadata ? 'feedback';
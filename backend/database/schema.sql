@@ -17,8 +17,8 @@ CREATE TYPE religion_type AS ENUM ('orthodox', 'muslim', 'protestant', 'catholic
 CREATE TYPE verification_status AS ENUM ('pending', 'approved', 'rejected');
 CREATE TYPE media_type AS ENUM ('photo', 'video');
 CREATE TYPE swipe_action AS ENUM ('like', 'pass', 'super_like');
-CREATE TYPE message_type AS ENUM ('text', 'photo', 'video', 'voice', 'sticker', 'gift', 'buna_invite');
-CREATE TYPE transaction_type AS ENUM ('purchase', 'gift_sent', 'gift_received', 'boost', 'refund', 'channel_subscription_reward', 'reveal');
+CREATE TYPE message_type AS ENUM ('text', 'photo', 'video', 'voice', 'sticker', 'gift', 'buna_invite', 'prompt_reply');
+CREATE TYPE transaction_type AS ENUM ('purchase', 'gift_sent', 'gift_received', 'boost', 'refund', 'channel_subscription_reward', 'reveal', 'rewind', 'super_like', 'match_extend');
 CREATE TYPE payment_method AS ENUM ('telebirr', 'cbe_birr', 'hellocash', 'amole');
 CREATE TYPE payment_status AS ENUM ('pending', 'completed', 'failed', 'refunded');
 CREATE TYPE payout_status AS ENUM ('pending', 'processing', 'completed', 'rejected');
@@ -1,8 +1,10 @@
 package database
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"lomi-backend/config"
 	"time"
@@ -136,3 +138,79 @@ func GeneratePresignedDownloadURL(ctx context.Context, bucket, key string, expir
 	return request.URL, nil
 }
 
+
+// GeneratePresignedPutURL generates a pre-signed upload URL bound to a content type and exact
+// size: the client must send the same Content-Type and Content-Length headers
+func GeneratePresignedPutURL(ctx context.Context, bucket, key, contentType string, contentLength int64, expiresIn time.Duration) (string, error) {
+	if S3Client == nil {
+		return "", fmt.Errorf("S3Client is not initialized")
+	}
+
+	presignClient := s3.NewPresignClient(S3Client)
+
+	request, err := presignClient.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: aws.Int64(contentLength),
+	}, func(opts *s3.PresignOptions) {
+		opts.Expires = expiresIn
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to generate presigned upload URL: %w", err)
+	}
+	return request.URL, nil
+}
+
+// HeadObject returns an object's metadata (size, content type) without downloading it
+func HeadObject(ctx context.Context, bucket, key string) (*s3.HeadObjectOutput, error) {
+	if S3Client == nil {
+		return nil, fmt.Errorf("S3Client is not initialized")
+	}
+	return S3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+}
+
+// DeleteObject removes an object from a bucket
+func DeleteObject(ctx context.Context, bucket, key string) error {
+	if S3Client == nil {
+		return fmt.Errorf("S3Client is not initialized")
+	}
+	_, err := S3Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// PutObject uploads a small object (generated files such as transcripts) from memory
+func PutObject(ctx context.Context, bucket, key, contentType string, body []byte) error {
+	if S3Client == nil {
+		return fmt.Errorf("S3Client is not initialized")
+	}
+	_, err := S3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+		Body:        bytes.NewReader(body),
+	})
+	return err
+}
+
+// GetObject downloads a small object into memory
+func GetObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	if S3Client == nil {
+		return nil, fmt.Errorf("S3Client is not initialized")
+	}
+	out, err := S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
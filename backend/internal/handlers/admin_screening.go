@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// GetFlaggedMessages lists messages the chat content filter matched, held ones first
+// GET /admin/messages/flagged?reviewed=false&action=hold&page=1&limit=50
+func GetFlaggedMessages(c *fiber.Ctx) error {
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 50)
+	offset := (page - 1) * limit
+
+	query := database.DB.Where("is_reviewed = ?", c.QueryBool("reviewed", false))
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+
+	var flagged []models.FlaggedMessage
+	if err := query.
+		Preload("Sender").
+		Order("CASE action WHEN 'hold' THEN 0 WHEN 'block' THEN 1 ELSE 2 END, created_at DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&flagged).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch flagged messages"})
+	}
+
+	return c.JSON(fiber.Map{
+		"messages": flagged,
+		"page":     page,
+		"limit":    limit,
+	})
+}
+
+// ReviewFlaggedMessage releases or rejects a held message, or dismisses a flag
+// PUT /admin/messages/flagged/:id/review {"decision": "release", "ban_sender": false}
+func ReviewFlaggedMessage(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	adminIDStr := claims["user_id"].(string)
+	adminID, _ := uuid.Parse(adminIDStr)
+
+	flaggedID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req struct {
+		Decision  string `json:"decision"` // "release", "reject", "dismiss"
+		BanSender bool   `json:"ban_sender,omitempty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	flagged, err := services.ReviewFlaggedMessage(adminID, flaggedID, models.FlaggedMessageDecision(req.Decision), req.BanSender)
+	if err != nil {
+		var validationErr *services.MessageValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Reason})
+		case errors.Is(err, services.ErrFlaggedMessageNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Flagged message not found"})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to review flagged message"})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Flagged message reviewed successfully",
+		"flagged": flagged,
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const boostCost = 199 // Coins per 30-minute boost
+
+var errBoostNoCity = errors.New("city not set")
+
+// ActivateBoost spends coins to put the user at the top of discovery in their city
+func ActivateBoost(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	if database.RedisClient == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Boosts are temporarily unavailable"})
+	}
+
+	var (
+		currentUser models.User
+		boost       *services.Boost
+	)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so concurrent purchases run one at a time: the second sees the first's boost
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&currentUser, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if currentUser.City == "" {
+			return errBoostNoCity
+		}
+		if currentUser.CoinBalance < boostCost {
+			return errInsufficientCoins
+		}
+
+		// Deduct coins
+		currentUser.CoinBalance -= boostCost
+		if err := tx.Model(&currentUser).Update("coin_balance", currentUser.CoinBalance).Error; err != nil {
+			return err
+		}
+
+		// Start the boost before committing so a failure here costs nothing
+		var err error
+		if boost, err = services.ActivateBoost(userID, currentUser.City); err != nil {
+			return err
+		}
+
+		transaction := models.CoinTransaction{
+			UserID:          userID,
+			TransactionType: models.TransactionTypeBoost,
+			CoinAmount:      -boostCost,
+			BalanceAfter:    currentUser.CoinBalance,
+			Metadata: models.JSONMap{
+				"city":       currentUser.City,
+				"started_at": boost.StartedAt,
+				"expires_at": boost.ExpiresAt,
+			},
+		}
+		return tx.Create(&transaction).Error
+	})
+
+	switch {
+	case errors.Is(err, errBoostNoCity):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Set your city before boosting"})
+	case errors.Is(err, errInsufficientCoins):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    "Insufficient coins",
+			"required": boostCost,
+			"balance":  currentUser.CoinBalance,
+		})
+	case errors.Is(err, services.ErrBoostActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": "Boost already active",
+			"boost": boost,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case err != nil:
+		// The boost was started but not paid for
+		if boost != nil {
+			if cancelErr := services.CancelBoost(userID, currentUser.City); cancelErr != nil {
+				log.Printf("⚠️ Failed to cancel unpaid boost for %s: %v", userID, cancelErr)
+			}
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start boost"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Boost activated",
+		"boost":          boost,
+		"coins_deducted": boostCost,
+		"new_balance":    currentUser.CoinBalance,
+	})
+}
+
+// GetBoostStatus returns the active boost, or the summary of the last one
+func GetBoostStatus(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	if database.RedisClient == nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "Boosts are temporarily unavailable"})
+	}
+
+	boost, err := services.GetBoost(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch boost"})
+	}
+
+	if boost == nil {
+		return c.JSON(fiber.Map{
+			"active": false,
+			"cost":   boostCost,
+		})
+	}
+
+	if boost.IsActive() {
+		return c.JSON(fiber.Map{
+			"active":            true,
+			"boost":             boost,
+			"remaining_seconds": int(time.Until(boost.ExpiresAt).Seconds()),
+			"cost":              boostCost,
+		})
+	}
+
+	// Post-boost summary. Counts cover everything received while boosted, not only
+	// what the boost added.
+	return c.JSON(fiber.Map{
+		"active": false,
+		"cost":   boostCost,
+		"last_boost": fiber.Map{
+			"started_at":          boost.StartedAt,
+			"ended_at":            boost.ExpiresAt,
+			"views_while_boosted": boost.Views,
+			"likes_while_boosted": boost.Likes,
+		},
+	})
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"log"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ProposeBuna invites the match for buna (coffee) at a time and café. The invite is a chat
+// message of type buna_invite; its state lives in the message metadata.
+// POST /chats/:id/buna {"scheduled_at": "2026-05-02T10:00:00+03:00", "cafe_name": "Tomoca", "cafe_address": "Piassa", "note": "..."}
+func ProposeBuna(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+	}
+
+	var req struct {
+		services.BunaProposal
+		ClientMessageID string `json:"client_message_id,omitempty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	message, duplicate, err := services.ProposeBuna(userID, matchID, req.BunaProposal, req.ClientMessageID)
+	if err != nil {
+		status, reason := messageError(err)
+		if status == fiber.StatusInternalServerError {
+			log.Printf("❌ Failed to propose buna: %v", err)
+		}
+		return c.Status(status).JSON(fiber.Map{"error": reason})
+	}
+
+	if duplicate {
+		return c.JSON(message)
+	}
+	return c.Status(fiber.StatusCreated).JSON(message)
+}
+
+// UpdateBuna accepts, declines, reschedules or cancels a buna invite
+// PUT /chats/:id/buna/:message_id {"action": "reschedule", "scheduled_at": "...", "cafe_name": "..."}
+func UpdateBuna(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+	}
+	messageID, err := uuid.Parse(c.Params("message_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid message ID"})
+	}
+
+	var req struct {
+		services.BunaProposal
+		Action string `json:"action"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	var proposal *services.BunaProposal
+	if services.BunaAction(req.Action) == services.BunaActionReschedule {
+		proposal = &req.BunaProposal
+	}
+
+	message, _, err := services.UpdateBunaInvite(userID, matchID, messageID, services.BunaAction(req.Action), proposal)
+	if err != nil {
+		status, reason := messageError(err)
+		if status == fiber.StatusInternalServerError {
+			log.Printf("❌ Failed to update buna %s: %v", messageID, err)
+			reason = "Failed to update buna invite"
+		}
+		return c.Status(status).JSON(fiber.Map{"error": reason})
+	}
+
+	return c.JSON(message)
+}
+
+// SubmitBunaFeedback answers the "how was it?" prompt after a buna. Not feeling safe (or
+// giving a report_reason) files a report against the other person.
+// POST /chats/:id/buna/:message_id/feedback {"rating": 4, "showed_up": true, "felt_safe": true, "comment": "..."}
+func SubmitBunaFeedback(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+	}
+	messageID, err := uuid.Parse(c.Params("message_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid message ID"})
+	}
+
+	var req struct {
+		Rating       int    `json:"rating"`
+		ShowedUp     *bool  `json:"showed_up"`
+		FeltSafe     *bool  `json:"felt_safe"`
+		Comment      string `json:"comment"`
+		ReportReason string `json:"report_reason,omitempty"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	reason := models.ReportReason(req.ReportReason)
+	switch reason {
+	case "", models.ReportReasonInappropriateContent, models.ReportReasonFakeProfile,
+		models.ReportReasonHarassment, models.ReportReasonScam, models.ReportReasonOther:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid report reason"})
+	}
+
+	feedback, err := services.SubmitBunaFeedback(userID, matchID, messageID, models.BunaFeedback{
+		Rating:   req.Rating,
+		ShowedUp: req.ShowedUp,
+		FeltSafe: req.FeltSafe,
+		Comment:  req.Comment,
+	}, reason)
+	if err != nil {
+		status, reason := messageError(err)
+		if status == fiber.StatusInternalServerError {
+			log.Printf("❌ Failed to save buna feedback for %s: %v", messageID, err)
+			reason = "Failed to save feedback"
+		}
+		return c.Status(status).JSON(fiber.Map{"error": reason})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":     "Thanks for telling us",
+		"reported":    feedback.ReportID != nil,
+		"report_id":   feedback.ReportID,
+		"received_at": feedback.CreatedAt.Format(time.RFC3339),
+	})
+}
@@ -1,74 +1,256 @@
 package handlers
 
 import (
+	"errors"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/ratelimit"
 	"lomi-backend/internal/services"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// GetChats returns all conversations for the current user
+const (
+	inboxDefaultLimit    = 50
+	inboxMaxLimit        = 100
+	messagesDefaultLimit = 50
+	messagesMaxLimit     = 100
+)
+
+// ChatUserSummary is the other participant as shown in the inbox
+type ChatUserSummary struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Age        int        `json:"age"`
+	Photo      string     `json:"photo,omitempty"`
+	IsVerified bool       `json:"is_verified"`
+	IsOnline   bool       `json:"is_online"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"` // Hidden when the user turned off online status
+}
+
+// ChatLastMessage is the latest message of a conversation's current epoch
+type ChatLastMessage struct {
+	ID          uuid.UUID          `json:"id"`
+	SenderID    uuid.UUID          `json:"sender_id"`
+	MessageType models.MessageType `json:"message_type"`
+	Content     string             `json:"content"`
+	MediaURL    string             `json:"media_url,omitempty"`
+	Seq         int64              `json:"seq"`
+	IsRead      bool               `json:"is_read"`
+	IsDeleted   bool               `json:"is_deleted,omitempty"` // Deleted for everyone
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+type ChatResponse struct {
+	MatchID        uuid.UUID        `json:"match_id"`
+	Epoch          int              `json:"epoch"`
+	LastSeq        int64            `json:"last_seq"`
+	User           ChatUserSummary  `json:"user"`
+	LastMessage    *ChatLastMessage `json:"last_message,omitempty"`
+	UnreadCount    int64            `json:"unread_count"`
+	LastActivityAt time.Time        `json:"last_activity_at"`
+	ExpiresAt      *time.Time       `json:"expires_at,omitempty"` // Set while nobody has written yet
+}
+
+// inboxRow is one row of inboxQuery
+type inboxRow struct {
+	MatchID        uuid.UUID
+	Epoch          int
+	LastSeq        int64
+	ExpiresAt      *time.Time
+	FirstMessageAt *time.Time
+	LastActivityAt time.Time
+
+	UserID           uuid.UUID
+	Name             string
+	Age              int
+	IsVerified       bool
+	IsOnline         bool
+	LastSeenAt       *time.Time
+	ShowOnlineStatus bool
+	PhotoURL         *string
+
+	LastMessageID   *uuid.UUID
+	LastSenderID    *uuid.UUID
+	LastMessageType *string
+	LastContent     *string
+	LastMediaURL    *string
+	LastMessageSeq  *int64
+	LastIsRead      *bool
+	LastIsDeleted   *bool
+	LastMessageAt   *time.Time
+
+	UnreadCount int64
+}
+
+// inboxQuery loads a page of the inbox in one round trip: the current epoch's last message,
+// sorted by last activity (a match without messages counts from when its epoch started);
+// then the other user's summary, first photo and unread count for that page only.
+const inboxQuery = `
+WITH inbox AS (
+	SELECT m.id AS match_id, m.epoch, m.last_seq, m.expires_at, m.first_message_at,
+		CASE WHEN m.user1_id = @user THEN m.user2_id ELSE m.user1_id END AS user_id,
+		lm.id AS last_message_id, lm.sender_id AS last_sender_id, lm.message_type AS last_message_type,
+		lm.content AS last_content, lm.media_url AS last_media_url, lm.seq AS last_message_seq,
+		lm.is_read AS last_is_read, lm.deleted_for_everyone_at IS NOT NULL AS last_is_deleted, lm.created_at AS last_message_at,
+		COALESCE(lm.created_at, m.epoch_started_at) AS last_activity_at
+	FROM matches m
+	LEFT JOIN LATERAL (
+		SELECT id, sender_id, message_type, content, media_url, seq, is_read, deleted_for_everyone_at, created_at
+		FROM messages
+		WHERE match_id = m.id AND epoch = m.epoch AND (held = false OR sender_id = @user)
+			AND NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = messages.id AND d.user_id = @user)
+		ORDER BY seq DESC
+		LIMIT 1
+	) lm ON true
+	WHERE (m.user1_id = @user OR m.user2_id = @user) AND m.status = @status
+)
+SELECT page.*, u.name, u.age, u.is_verified, u.is_online, u.last_seen_at, u.show_online_status,
+	photo.url AS photo_url, unread.count AS unread_count
+FROM (
+	SELECT * FROM inbox
+	WHERE @cursor_at::timestamptz IS NULL OR (last_activity_at, match_id) < (@cursor_at::timestamptz, @cursor_id::uuid)
+	ORDER BY last_activity_at DESC, match_id DESC
+	LIMIT @limit
+) page
+JOIN users u ON u.id = page.user_id
+LEFT JOIN LATERAL (
+	SELECT url FROM media
+	WHERE user_id = page.user_id AND media_type = 'photo' AND is_approved = true
+	ORDER BY display_order ASC
+	LIMIT 1
+) photo ON true
+LEFT JOIN LATERAL (
+	SELECT COUNT(*) AS count FROM messages
+	WHERE match_id = page.match_id AND epoch = page.epoch AND receiver_id = @user AND is_read = false AND held = false
+) unread ON true
+ORDER BY page.last_activity_at DESC, page.match_id DESC`
+
+// parseInboxCursor reads a cursor of the form "<last_activity_at RFC3339Nano>|<match_id>"
+func parseInboxCursor(cursor string) (*time.Time, uuid.UUID, bool) {
+	at, id, found := strings.Cut(cursor, "|")
+	if !found {
+		return nil, uuid.Nil, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, at)
+	if err != nil {
+		return nil, uuid.Nil, false
+	}
+	matchID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, uuid.Nil, false
+	}
+	return &t, matchID, true
+}
+
+// GetChats returns the current user's conversations, most recent activity first.
+// Paginate with ?limit= and ?cursor= (next_cursor from the previous page).
 func GetChats(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
 	userIDStr := claims["user_id"].(string)
 	userID, _ := uuid.Parse(userIDStr)
 
-	var matches []models.Match
-	if err := database.DB.Where("(user1_id = ? OR user2_id = ?) AND is_active = ?", userID, userID, true).
-		Preload("User1").
-		Preload("User2").
-		Find(&matches).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch chats"})
+	limit := c.QueryInt("limit", inboxDefaultLimit)
+	if limit <= 0 || limit > inboxMaxLimit {
+		limit = inboxDefaultLimit
 	}
 
-	type ChatResponse struct {
-		MatchID     uuid.UUID       `json:"match_id"`
-		User        models.User      `json:"user"`
-		LastMessage *models.Message  `json:"last_message,omitempty"`
-		UnreadCount int64            `json:"unread_count"`
+	var cursorAt *time.Time
+	cursorID := uuid.Nil
+	if cursor := c.Query("cursor"); cursor != "" {
+		var ok bool
+		if cursorAt, cursorID, ok = parseInboxCursor(cursor); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
 	}
 
-	chats := make([]ChatResponse, 0)
-	for _, match := range matches {
-		var otherUser models.User
-		if match.User1ID == userID {
-			otherUser = match.User2
-		} else {
-			otherUser = match.User1
-		}
+	var rows []inboxRow
+	if err := database.DB.Raw(inboxQuery, map[string]interface{}{
+		"user":      userID,
+		"status":    models.MatchStatusActive,
+		"cursor_at": cursorAt,
+		"cursor_id": cursorID,
+		"limit":     limit,
+	}).Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch chats"})
+	}
 
-		// Get last message
-		var lastMessage models.Message
-		database.DB.Where("match_id = ?", match.ID).
-			Order("created_at DESC").
-			First(&lastMessage)
-
-		// Count unread messages
-		var unreadCount int64
-		database.DB.Model(&models.Message{}).
-			Where("match_id = ? AND receiver_id = ? AND is_read = ?", match.ID, userID, false).
-			Count(&unreadCount)
-
-		chats = append(chats, ChatResponse{
-			MatchID:     match.ID,
-			User:        otherUser,
-			LastMessage: &lastMessage,
-			UnreadCount: unreadCount,
-		})
+	// Live presence from Redis; users.is_online is only a fallback
+	peerIDs := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		peerIDs[i] = row.UserID
+	}
+	online := onlineUsers(peerIDs)
+
+	chats := make([]ChatResponse, 0, len(rows))
+	for _, row := range rows {
+		chat := ChatResponse{
+			MatchID: row.MatchID,
+			Epoch:   row.Epoch,
+			LastSeq: row.LastSeq,
+			User: ChatUserSummary{
+				ID:         row.UserID,
+				Name:       row.Name,
+				Age:        row.Age,
+				IsVerified: row.IsVerified,
+			},
+			UnreadCount:    row.UnreadCount,
+			LastActivityAt: row.LastActivityAt,
+		}
+		if row.PhotoURL != nil {
+			chat.User.Photo = *row.PhotoURL
+		}
+		if row.ShowOnlineStatus {
+			chat.User.IsOnline = row.IsOnline
+			if online != nil {
+				chat.User.IsOnline = online[row.UserID]
+			}
+			chat.User.LastSeenAt = row.LastSeenAt
+		}
+		if row.FirstMessageAt == nil {
+			chat.ExpiresAt = row.ExpiresAt
+		}
+		if row.LastMessageID != nil {
+			chat.LastMessage = &ChatLastMessage{
+				ID:          *row.LastMessageID,
+				SenderID:    *row.LastSenderID,
+				MessageType: models.MessageType(*row.LastMessageType),
+				Seq:         *row.LastMessageSeq,
+				IsRead:      *row.LastIsRead,
+				IsDeleted:   row.LastIsDeleted != nil && *row.LastIsDeleted,
+				CreatedAt:   *row.LastMessageAt,
+			}
+			if row.LastContent != nil {
+				chat.LastMessage.Content = *row.LastContent
+			}
+			if row.LastMediaURL != nil {
+				chat.LastMessage.MediaURL = services.ChatMediaURL(*row.LastMediaURL)
+			}
+		}
+		chats = append(chats, chat)
 	}
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"chats": chats,
 		"count": len(chats),
-	})
+	}
+	if len(rows) == limit {
+		last := rows[len(rows)-1]
+		response["next_cursor"] = last.LastActivityAt.UTC().Format(time.RFC3339Nano) + "|" + last.MatchID.String()
+	}
+
+	return c.JSON(response)
 }
 
-// GetMessages returns messages for a specific match
+// GetMessages returns messages for a specific match, newest first.
+// Paginate with ?before=<seq> for older messages; ?after=<seq> returns newer messages
+// oldest first (catching up). ?page= offset paging is kept for older clients.
 func GetMessages(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
@@ -77,8 +259,15 @@ func GetMessages(c *fiber.Ctx) error {
 
 	matchID := c.Params("id")
 	page := c.QueryInt("page", 1)
-	limit := c.QueryInt("limit", 50)
-	offset := (page - 1) * limit
+	limit := c.QueryInt("limit", messagesDefaultLimit)
+	if limit <= 0 || limit > messagesMaxLimit {
+		limit = messagesDefaultLimit
+	}
+	before := c.QueryInt("before", 0)
+	after := c.QueryInt("after", 0)
+	if before < 0 || after < 0 || (before > 0 && after > 0) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Use either before or after"})
+	}
 
 	// Verify user is part of this match
 	var match models.Match
@@ -87,32 +276,92 @@ func GetMessages(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Match not found"})
 	}
 
-	var messages []models.Message
-	if err := database.DB.Where("match_id = ?", matchID).
+	// Only the current epoch is shown; earlier epochs ended in an unmatch or expiry
+	// Messages the user deleted for themselves, and others' messages held for review, are
+	// left out; tombstones are kept
+	query := database.DB.Where("match_id = ? AND epoch = ?", matchID, match.Epoch).
+		Where("held = ? OR sender_id = ?", false, userID).
+		Where("NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = messages.id AND d.user_id = ?)", userID).
 		Preload("Sender").
 		Preload("Receiver").
 		Preload("Gift").
-		Order("created_at DESC").
-		Limit(limit).
-		Offset(offset).
-		Find(&messages).Error; err != nil {
+		Preload("Reactions", func(db *gorm.DB) *gorm.DB { return db.Order("created_at ASC") })
+
+	switch {
+	case after > 0:
+		query = query.Where("seq > ?", after).Order("seq ASC")
+	case before > 0:
+		query = query.Where("seq < ?", before).Order("seq DESC")
+	default:
+		query = query.Order("seq DESC").Offset((page - 1) * limit)
+	}
+
+	// One extra row tells whether there is more
+	var messages []models.Message
+	if err := query.Limit(limit + 1).Find(&messages).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch messages"})
 	}
 
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+	services.PresignChatMessages(messages)
+
 	// Mark messages as read
 	now := time.Now()
 	database.DB.Model(&models.Message{}).
-		Where("match_id = ? AND receiver_id = ? AND is_read = ?", matchID, userID, false).
+		Where("match_id = ? AND receiver_id = ? AND is_read = ? AND held = ?", matchID, userID, false, false).
 		Updates(map[string]interface{}{
 			"is_read": true,
 			"read_at": now,
 		})
 
-	return c.JSON(fiber.Map{
+	response := fiber.Map{
 		"messages": messages,
 		"page":     page,
 		"limit":    limit,
-	})
+		"has_more": hasMore,
+	}
+	if len(messages) > 0 {
+		// Cursors for the next request in the same direction
+		if after > 0 {
+			response["next_after"] = messages[len(messages)-1].Seq
+		} else {
+			response["next_before"] = messages[len(messages)-1].Seq
+		}
+	}
+
+	return c.JSON(response)
+}
+
+// messageError maps a services.SendChatMessage error to an HTTP status and message
+func messageError(err error) (int, string) {
+	var validationErr *services.MessageValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return fiber.StatusBadRequest, validationErr.Reason
+	case errors.Is(err, services.ErrMatchNotFound):
+		return fiber.StatusNotFound, "Match not found"
+	case errors.Is(err, services.ErrMessageBlocked):
+		return fiber.StatusForbidden, "Cannot send message: user is blocked"
+	case errors.Is(err, services.ErrMessageRateLimited):
+		return fiber.StatusTooManyRequests, "Rate limit exceeded"
+	case errors.Is(err, services.ErrMessageScreened):
+		return fiber.StatusUnprocessableEntity, "This message can't be sent. For your safety, keep payments and contact details out of chat until you've met."
+	case errors.Is(err, services.ErrMessageNotFound):
+		return fiber.StatusNotFound, "Message not found"
+	case errors.Is(err, services.ErrMessageNotOwner):
+		return fiber.StatusForbidden, "Only the sender can change this message"
+	case errors.Is(err, services.ErrMessageLocked):
+		return fiber.StatusConflict, err.Error()
+	case errors.Is(err, services.ErrBunaNotFound):
+		return fiber.StatusNotFound, "Buna invite not found"
+	case errors.Is(err, services.ErrBunaState):
+		return fiber.StatusConflict, err.Error()
+	default:
+		return fiber.StatusInternalServerError, "Failed to send message"
+	}
 }
 
 // SendMessage creates a new message
@@ -127,73 +376,58 @@ func SendMessage(c *fiber.Ctx) error {
 		MessageType string                 `json:"message_type"`
 		Content     string                 `json:"content,omitempty"`
 		MediaURL    string                 `json:"media_url,omitempty"`
-		GiftID      string                 `json:"gift_id,omitempty"`
+		MediaID     string                 `json:"media_id,omitempty"` // From POST /chats/:id/media/upload-url
 		Metadata    map[string]interface{} `json:"metadata,omitempty"`
+		// Client-generated ID; resending with the same ID returns the original message
+		ClientMessageID string `json:"client_message_id,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
+	if req.MatchID == "" {
+		req.MatchID = c.Params("id")
+	}
 
 	matchID, err := uuid.Parse(req.MatchID)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
 	}
 
-	// Verify user is part of this match
-	var match models.Match
-	if err := database.DB.Where("id = ? AND (user1_id = ? OR user2_id = ?) AND is_active = ?", matchID, senderID, senderID, true).
-		First(&match).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Match not found"})
-	}
-
-	// Determine receiver
-	var receiverID uuid.UUID
-	if match.User1ID == senderID {
-		receiverID = match.User2ID
-	} else {
-		receiverID = match.User1ID
-	}
-
-	// Check if sender is blocked by receiver
-	var block models.Block
-	if err := database.DB.Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", receiverID, senderID, senderID, receiverID).First(&block).Error; err == nil {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Cannot send message: user is blocked"})
-	}
-
-	// Create message
-	message := models.Message{
-		MatchID:     matchID,
-		SenderID:    senderID,
-		ReceiverID:  receiverID,
-		MessageType: models.MessageType(req.MessageType),
-		Content:     req.Content,
-		MediaURL:    req.MediaURL,
-		IsRead:      false,
-	}
-
-	if req.GiftID != "" {
-		giftID, _ := uuid.Parse(req.GiftID)
-		message.GiftID = &giftID
-	}
-
-	if req.Metadata != nil {
-		message.Metadata = models.JSONMap(req.Metadata)
+	var mediaID uuid.UUID
+	if req.MediaID != "" {
+		if mediaID, err = uuid.Parse(req.MediaID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid media ID"})
+		}
 	}
 
-	if err := database.DB.Create(&message).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to send message"})
+	message, duplicate, err := services.SendChatMessage(senderID, services.SendMessageInput{
+		MatchID:         matchID,
+		MessageType:     models.MessageType(req.MessageType),
+		Content:         req.Content,
+		MediaURL:        req.MediaURL,
+		MediaID:         mediaID,
+		Metadata:        req.Metadata,
+		ClientMessageID: req.ClientMessageID,
+	})
+	if err != nil {
+		status, reason := messageError(err)
+		if status == fiber.StatusTooManyRequests {
+			return c.Status(status).JSON(fiber.Map{
+				"error":       reason,
+				"retry_after": int(ratelimit.Message.Window.Seconds()),
+			})
+		}
+		return c.Status(status).JSON(fiber.Map{"error": reason})
 	}
 
 	// Load relations for response
-	database.DB.Preload("Sender").Preload("Receiver").Preload("Gift").First(&message, message.ID)
-
-	// Send push notification (async)
-	go func() {
-		if services.NotificationSvc != nil {
-			services.NotificationSvc.NotifyNewMessage(message, message.Sender)
-		}
-	}()
+	database.DB.Preload("Sender").Preload("Receiver").Preload("Gift").First(message, message.ID)
+	message.MediaURL = services.ChatMediaURL(message.MediaURL)
 
+	// A resend returns what was stored the first time
+	if duplicate {
+		return c.JSON(message)
+	}
 	return c.Status(fiber.StatusCreated).JSON(message)
 }
 
@@ -215,7 +449,7 @@ func MarkMessagesAsRead(c *fiber.Ctx) error {
 
 	now := time.Now()
 	if err := database.DB.Model(&models.Message{}).
-		Where("match_id = ? AND receiver_id = ? AND is_read = ?", matchID, userID, false).
+		Where("match_id = ? AND receiver_id = ? AND is_read = ? AND held = ?", matchID, userID, false, false).
 		Updates(map[string]interface{}{
 			"is_read": true,
 			"read_at": now,
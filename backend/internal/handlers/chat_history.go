@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"lomi-backend/internal/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 50
+)
+
+// SearchMessages searches the text of the user's conversations, newest first
+// GET /chats/search?q=buna&match_id=&limit=20&cursor=
+func SearchMessages(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	limit := c.QueryInt("limit", searchDefaultLimit)
+	if limit <= 0 || limit > searchMaxLimit {
+		limit = searchDefaultLimit
+	}
+	search := services.MessageSearch{Query: c.Query("q"), Limit: limit}
+
+	if matchIDStr := c.Query("match_id"); matchIDStr != "" {
+		matchID, err := uuid.Parse(matchIDStr)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+		}
+		search.MatchID = &matchID
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		var ok bool
+		// Same "created_at|id" shape as the inbox cursor, keyed by message
+		if search.BeforeAt, search.BeforeID, ok = parseInboxCursor(cursor); !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+	}
+
+	results, err := services.SearchMessages(userID, search)
+	if err != nil {
+		status, msg := messageError(err)
+		if status == fiber.StatusInternalServerError {
+			msg = "Failed to search messages"
+		}
+		return c.Status(status).JSON(fiber.Map{"error": msg})
+	}
+
+	response := fiber.Map{
+		"results": results,
+		"count":   len(results),
+	}
+	if len(results) == limit {
+		last := results[len(results)-1]
+		response["next_cursor"] = last.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + last.ID.String()
+	}
+	return c.JSON(response)
+}
+
+// ExportChat downloads a transcript of a conversation, with media links that expire
+// GET /chats/:id/export?format=json|html
+func ExportChat(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+	}
+
+	format := c.Query("format", "json")
+	if format != "json" && format != "html" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "format must be json or html"})
+	}
+
+	transcript, err := services.ExportChat(userID, matchID)
+	if err != nil {
+		if errors.Is(err, services.ErrMatchNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Match not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export chat"})
+	}
+
+	filename := fmt.Sprintf("lomi-chat-%s-%s.%s", matchID.String()[:8], transcript.ExportedAt.Format("20060102"), format)
+	c.Attachment(filename)
+	if format == "html" {
+		page, err := transcript.RenderHTML()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to export chat"})
+		}
+		c.Type("html", "utf-8")
+		return c.Send(page)
+	}
+	return c.JSON(transcript)
+}
+
+// GetReportTranscript returns the chat transcript attached to a report
+// GET /admin/reports/:id/transcript
+func GetReportTranscript(c *fiber.Ctx) error {
+	reportID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	transcript, err := services.ReportTranscript(reportID)
+	if err != nil {
+		if errors.Is(err, services.ErrTranscriptNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Transcript not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to load transcript"})
+	}
+	return c.JSON(fiber.Map{"transcript": transcript})
+}
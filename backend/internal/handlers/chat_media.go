@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// GetChatMediaUploadURL hands out a presigned upload for a photo, video or voice note in a chat.
+// The client PUTs the file with the returned headers, then sends a message with media_id.
+// POST /chats/:id/media/upload-url {"media_type": "voice", "content_type": "audio/ogg", "size_bytes": 48213, "duration_seconds": 7}
+func GetChatMediaUploadURL(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+	}
+
+	var req struct {
+		MediaType       string `json:"media_type"`
+		ContentType     string `json:"content_type"`
+		SizeBytes       int64  `json:"size_bytes"`
+		DurationSeconds int    `json:"duration_seconds"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	upload, err := services.CreateChatMediaUpload(userID, matchID, services.ChatMediaUploadRequest{
+		MediaType:       models.MessageType(req.MediaType),
+		ContentType:     req.ContentType,
+		SizeBytes:       req.SizeBytes,
+		DurationSeconds: req.DurationSeconds,
+	})
+	if err != nil {
+		var validationErr *services.MessageValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Reason})
+		case errors.Is(err, services.ErrMatchNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Match not found"})
+		default:
+			log.Printf("❌ Failed to create chat media upload: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate upload URL"})
+		}
+	}
+
+	return c.JSON(upload)
+}
+
+// GetChatMediaURL returns a fresh download URL for a file sent in the chat (signed URLs in
+// message responses expire after an hour). Only the two participants can fetch it.
+func GetChatMediaURL(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	matchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+	}
+	mediaID, err := uuid.Parse(c.Params("media_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid media ID"})
+	}
+
+	url, err := services.ChatMediaDownloadURL(userID, matchID, mediaID)
+	if err != nil {
+		if errors.Is(err, services.ErrMatchNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Media not found"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate download URL"})
+	}
+
+	return c.JSON(fiber.Map{"url": url})
+}
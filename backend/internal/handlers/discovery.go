@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log"
 	"lomi-backend/config"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
@@ -12,9 +14,23 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetSwipeCards returns potential matches for swiping
+// SwipeCard is a candidate profile as shown in the swipe deck
+type SwipeCard struct {
+	User     models.User    `json:"user"`
+	Photos   []models.Media `json:"photos"`
+	Video    *models.Media  `json:"video,omitempty"`
+	Distance float64        `json:"distance"`
+	// Answers to profile prompts; a like can reply to one (prompt_answer_id)
+	Prompts []models.ProfilePrompt `json:"prompts"`
+}
+
+// GetSwipeCards returns potential matches for swiping.
+// Cards are served from a ranked deck session in Redis: pass the returned
+// next_cursor to get the following page, or refresh=true to rebuild the deck.
 func GetSwipeCards(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
@@ -26,42 +42,146 @@ func GetSwipeCards(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	// Get user preferences
+	_, _, maxDistance := swipePreferences(currentUser)
+
+	// Without Redis there is no deck session: rank and serve the top page directly
+	if database.RedisClient == nil {
+		ranked, err := rankedSwipeCandidateIDs(currentUser)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch users"})
+		}
+		if len(ranked) > swipeCardsPerPage {
+			ranked = ranked[:swipeCardsPerPage]
+		}
+		cards := buildSwipeCards(currentUser, ranked)
+		return c.JSON(fiber.Map{
+			"cards":        cards,
+			"count":        len(cards),
+			"max_distance": maxDistance,
+		})
+	}
+
+	var sessionID string
+	offset := 0
+	reset := false
+
+	if cursor := c.Query("cursor"); cursor != "" && !c.QueryBool("refresh", false) {
+		var err error
+		sessionID, offset, err = services.ParseSwipeDeckCursor(cursor)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+	} else if !c.QueryBool("refresh", false) {
+		// No cursor: resume the current session from the top (swiped cards are skipped)
+		sessionID, _ = services.SwipeDeckSession(userID)
+	}
+
+	// Boosted profiles in the viewer's city go to the top of the deck
+	promoteBoostedCandidates(currentUser)
+
+	page, err := serveSwipeDeckPage(currentUser, sessionID, offset)
+	if err == services.ErrSwipeDeckStale && sessionID != "" {
+		reset = true
+		page, err = serveSwipeDeckPage(currentUser, "", 0)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch users"})
+	}
+
+	cards := buildSwipeCards(currentUser, page.CandidateIDs)
+
+	return c.JSON(fiber.Map{
+		"cards":        cards,
+		"count":        len(cards),
+		"max_distance": maxDistance,
+		"session_id":   page.SessionID,
+		"next_cursor":  page.NextCursor(),
+		"has_more":     page.Remaining > 0,
+		"reset":        reset,
+	})
+}
+
+// serveSwipeDeckPage serves a page from the given session, building a new deck when
+// there is no session or the current one is exhausted at the first page.
+func serveSwipeDeckPage(viewer models.User, sessionID string, offset int) (*services.SwipeDeckPage, error) {
+	if sessionID != "" {
+		page, err := services.NextSwipeDeckPage(viewer.ID, sessionID, offset, swipeCardsPerPage)
+		if err != nil || len(page.CandidateIDs) > 0 || page.Remaining > 0 {
+			return page, err
+		}
+		// Deck exhausted: fall through and rebuild from the database
+	}
+
+	ranked, err := rankedSwipeCandidateIDs(viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	newSession, err := services.NewSwipeDeck(viewer.ID, ranked)
+	if err != nil {
+		return nil, err
+	}
+
+	return services.NextSwipeDeckPage(viewer.ID, newSession, 0, swipeCardsPerPage)
+}
+
+// promoteBoostedCandidates pushes eligible, currently boosted users in the viewer's city
+// to the front of the viewer's deck (each at most once per deck session)
+func promoteBoostedCandidates(viewer models.User) {
+	boostedIDs, err := services.ActiveBoostedUsers(viewer.City)
+	if err != nil || len(boostedIDs) == 0 {
+		return
+	}
+
+	var eligible []uuid.UUID
+	if err := swipeCandidateQuery(viewer).Where("users.id IN ?", boostedIDs).Pluck("users.id", &eligible).Error; err != nil || len(eligible) == 0 {
+		return
+	}
+
+	// Keep the boost order (most recent first)
+	isEligible := make(map[uuid.UUID]bool, len(eligible))
+	for _, id := range eligible {
+		isEligible[id] = true
+	}
+	ordered := make([]uuid.UUID, 0, len(eligible))
+	for _, id := range boostedIDs {
+		if isEligible[id] {
+			ordered = append(ordered, id)
+		}
+	}
+
+	if err := services.PromoteSwipeDeckCards(viewer.ID, ordered); err != nil {
+		log.Printf("⚠️ Failed to promote boosted profiles: %v", err)
+	}
+}
+
+// swipePreferences returns the viewer's age range and max distance (km)
+func swipePreferences(u models.User) (int, int, float64) {
 	var minAge, maxAge int = 18, 100
-	// TODO: Implement distance-based filtering when location data is available
-	// var maxDistance float64 = 50 // km
-	if prefs, ok := currentUser.Preferences["min_age"].(float64); ok {
+	maxDistance := float64(config.Cfg.DiscoveryMaxDistanceKm) // km
+	if prefs, ok := u.Preferences["min_age"].(float64); ok {
 		minAge = int(prefs)
 	}
-	if prefs, ok := currentUser.Preferences["max_age"].(float64); ok {
+	if prefs, ok := u.Preferences["max_age"].(float64); ok {
 		maxAge = int(prefs)
 	}
-	// if prefs, ok := currentUser.Preferences["max_distance"].(float64); ok {
-	// 	maxDistance = prefs
-	// }
+	if prefs, ok := u.Preferences["max_distance"].(float64); ok && prefs > 0 {
+		maxDistance = prefs
+	}
+	return minAge, maxAge, maxDistance
+}
 
-	// Get already swiped user IDs
-	var swipedIDs []uuid.UUID
-	database.DB.Model(&models.Swipe{}).
-		Where("swiper_id = ?", userID).
-		Pluck("swiped_id", &swipedIDs)
-
-	// Get blocked users
-	var blockedIDs []uuid.UUID
-	database.DB.Model(&models.Block{}).
-		Where("blocker_id = ? OR blocked_id = ?", userID, userID).
-		Pluck("blocked_id", &swipedIDs)
-	database.DB.Model(&models.Block{}).
-		Where("blocker_id = ? OR blocked_id = ?", userID, userID).
-		Pluck("blocker_id", &swipedIDs)
-
-	// Build query
-	query := database.DB.Where("id != ?", userID).
-		Where("is_active = ?", true).
+// swipeCandidateQuery builds the users query for everyone the viewer may be shown in the deck
+func swipeCandidateQuery(currentUser models.User) *gorm.DB {
+	userID := currentUser.ID
+	minAge, maxAge, maxDistance := swipePreferences(currentUser)
+
+	// Build query: blocked, unmatched, reported, inactive and photo-less users
+	// and anyone already swiped are excluded
+	query := database.DB.Model(&models.User{}).
+		Scopes(discoveryExclusions(userID), notSwipedBy(userID, "users.id")).
 		Where("age >= ? AND age <= ?", minAge, maxAge).
-		Where("city = ?", currentUser.City). // Same city for now
-		Where("id NOT IN ?", swipedIDs).
-		Where("id NOT IN ?", blockedIDs)
+		Scopes(withinDiscoveryRadius(currentUser, maxDistance))
 
 	// Gender preference - check user's looking_for preference
 	var lookingFor string
@@ -82,54 +202,172 @@ func GetSwipeCards(c *fiber.Ctx) error {
 		query = query.Where("gender = ?", models.GenderFemale)
 	}
 
-	// Limit to 20 cards per request
-	var users []models.User
-	if err := query.Limit(20).Order("created_at DESC").Find(&users).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch users"})
+	return query
+}
+
+// rankedSwipeCandidateIDs fetches the candidate pool for a viewer and returns it in ranked order
+func rankedSwipeCandidateIDs(currentUser models.User) ([]uuid.UUID, error) {
+	_, _, maxDistance := swipePreferences(currentUser)
+	query := swipeCandidateQuery(currentUser)
+
+	// Fetch a pool of the nearest candidates, then rank it
+	var pool []models.User
+	if err := query.Scopes(orderByDistance(currentUser)).Order("created_at DESC").Limit(swipeCandidatePoolSize).Find(&pool).Error; err != nil {
+		return nil, err
 	}
 
-	// Get photos for each user
-	type UserCard struct {
-		User     models.User    `json:"user"`
-		Photos   []models.Media `json:"photos"`
-		Video    *models.Media  `json:"video,omitempty"`
-		Distance float64        `json:"distance"`
+	ranked := rankSwipeCandidates(currentUser, maxDistance, pool)
+	ids := make([]uuid.UUID, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.User.ID
+	}
+	return ids, nil
+}
+
+// buildSwipeCards loads users, photos and videos for a page of candidate IDs in batched
+// queries and returns cards in the same order as ids
+func buildSwipeCards(viewer models.User, ids []uuid.UUID) []SwipeCard {
+	cards := make([]SwipeCard, 0, len(ids))
+	if len(ids) == 0 {
+		return cards
 	}
 
-	cards := make([]UserCard, 0)
+	// Count the impression for anyone currently boosted
+	services.RecordBoostViews(ids)
+
+	var users []models.User
+	database.DB.Where("users.id IN ?", ids).Scopes(notViewer(viewer.ID, "users.id"), hiddenFromViewer(viewer.ID, "users.id")).
+		Where("users.is_active = ?", true).Find(&users)
+	usersByID := make(map[uuid.UUID]models.User, len(users))
 	for _, u := range users {
-		var photos []models.Media
-		database.DB.Where("user_id = ? AND media_type = ? AND is_approved = ?", u.ID, models.MediaTypePhoto, true).
-			Order("display_order ASC").Limit(9).Find(&photos)
+		usersByID[u.ID] = u
+	}
+
+	var media []models.Media
+	database.DB.Where("user_id IN ? AND is_approved = ?", ids, true).
+		Order("display_order ASC").
+		Find(&media)
+
+	photos := make(map[uuid.UUID][]models.Media, len(ids))
+	videos := make(map[uuid.UUID]*models.Media, len(ids))
+	for i := range media {
+		m := media[i]
+		switch m.MediaType {
+		case models.MediaTypePhoto:
+			if len(photos[m.UserID]) < 9 {
+				photos[m.UserID] = append(photos[m.UserID], m)
+			}
+		case models.MediaTypeVideo:
+			if videos[m.UserID] == nil {
+				videos[m.UserID] = &m
+			}
+		}
+	}
+
+	prompts, err := services.ProfilePrompts(ids)
+	if err != nil {
+		log.Printf("⚠️ Failed to load profile prompts: %v", err)
+	}
 
-		var video models.Media
-		hasVideo := database.DB.Where("user_id = ? AND media_type = ? AND is_approved = ?", u.ID, models.MediaTypeVideo, true).
-			First(&video).Error == nil
+	for _, id := range ids {
+		u, ok := usersByID[id]
+		if !ok {
+			continue // Deactivated, blocked or reported since the deck was built
+		}
 
-		// Calculate distance (Haversine formula simplified)
+		// Calculate distance (Haversine)
 		distance := 0.0
-		if currentUser.Latitude != 0 && currentUser.Longitude != 0 && u.Latitude != 0 && u.Longitude != 0 {
-			distance = calculateDistance(currentUser.Latitude, currentUser.Longitude, u.Latitude, u.Longitude)
+		if hasLocation(viewer) && hasLocation(u) {
+			distance = calculateDistance(viewer.Latitude, viewer.Longitude, u.Latitude, u.Longitude)
+		}
+
+		userPhotos := photos[id]
+		if userPhotos == nil {
+			userPhotos = []models.Media{}
+		}
+
+		userPrompts := prompts[id]
+		if userPrompts == nil {
+			userPrompts = []models.ProfilePrompt{}
 		}
 
-		card := UserCard{
+		cards = append(cards, SwipeCard{
 			User:     u,
-			Photos:   photos,
+			Photos:   userPhotos,
+			Video:    videos[id],
 			Distance: distance,
+			Prompts:  userPrompts,
+		})
+	}
+
+	return cards
+}
+
+const (
+	swipeCandidatePoolSize = 200 // Candidates fetched from the DB before ranking
+	swipeCardsPerPage      = 20
+)
+
+// rankSwipeCandidates scores a candidate pool with the discovery ranker.
+// Inbound like stats for the whole pool are loaded in a single grouped query.
+func rankSwipeCandidates(viewer models.User, maxDistance float64, pool []models.User) []services.RankedCandidate {
+	if len(pool) == 0 {
+		return []services.RankedCandidate{}
+	}
+
+	ids := make([]uuid.UUID, len(pool))
+	for i, u := range pool {
+		ids[i] = u.ID
+	}
+
+	var stats []struct {
+		SwipedID uuid.UUID
+		Likes    int64
+		Swipes   int64
+	}
+	database.DB.Model(&models.Swipe{}).
+		Select("swiped_id, COUNT(*) FILTER (WHERE action IN ?) AS likes, COUNT(*) AS swipes",
+			[]models.SwipeAction{models.SwipeActionLike, models.SwipeActionSuperLike}).
+		Where("swiped_id IN ?", ids).
+		Group("swiped_id").
+		Scan(&stats)
+
+	likeStats := make(map[uuid.UUID][2]int64, len(stats))
+	for _, s := range stats {
+		likeStats[s.SwipedID] = [2]int64{s.Likes, s.Swipes}
+	}
+
+	candidates := make([]services.RankingCandidate, len(pool))
+	for i, u := range pool {
+		distance := -1.0
+		if hasLocation(viewer) && hasLocation(u) {
+			distance = calculateDistance(viewer.Latitude, viewer.Longitude, u.Latitude, u.Longitude)
 		}
-		if hasVideo {
-			card.Video = &video
+		candidates[i] = services.RankingCandidate{
+			User:           u,
+			DistanceKm:     distance,
+			LikesReceived:  likeStats[u.ID][0],
+			SwipesReceived: likeStats[u.ID][1],
 		}
-		cards = append(cards, card)
 	}
 
-	return c.JSON(fiber.Map{
-		"cards": cards,
-		"count": len(cards),
-	})
+	return services.DiscoveryRanker.Rank(services.RankingViewer{User: viewer, MaxDistanceKm: maxDistance}, candidates)
+}
+
+var errAlreadySwiped = errors.New("already swiped")
+
+// swipePairLockKey identifies an unordered pair of users for pg_advisory_xact_lock
+func swipePairLockKey(a, b uuid.UUID) string {
+	if a.String() > b.String() {
+		a, b = b, a
+	}
+	return "swipe:" + a.String() + ":" + b.String()
 }
 
-// SwipeAction handles like/pass/super_like actions
+// SwipeAction handles like/pass/super_like actions.
+// The swipe, any super like charge and the match are written in one transaction, serialised
+// per pair of users so simultaneous mutual likes create exactly one match. Clients may send
+// an Idempotency-Key header to retry safely (see middleware.Idempotency).
 func SwipeAction(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
@@ -139,6 +377,9 @@ func SwipeAction(c *fiber.Ctx) error {
 	var req struct {
 		SwipedID string `json:"swiped_id"`
 		Action   string `json:"action"` // "like", "pass", "super_like"
+		// Optional reply to one of the swiped user's prompts (likes only)
+		PromptAnswerID string `json:"prompt_answer_id,omitempty"`
+		Comment        string `json:"comment,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
@@ -148,79 +389,232 @@ func SwipeAction(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
+	if swipedID == swiperID {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot swipe on yourself"})
+	}
 
-	// Check if already swiped
-	var existingSwipe models.Swipe
-	if err := database.DB.Where("swiper_id = ? AND swiped_id = ?", swiperID, swipedID).First(&existingSwipe).Error; err == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Already swiped"})
+	action := models.SwipeAction(req.Action)
+	if action != models.SwipeActionLike && action != models.SwipeActionPass && action != models.SwipeActionSuperLike {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid action"})
 	}
+	isLike := action == models.SwipeActionLike || action == models.SwipeActionSuperLike
 
-	// Create swipe record
 	swipe := models.Swipe{
 		SwiperID: swiperID,
 		SwipedID: swipedID,
-		Action:   models.SwipeAction(req.Action),
+		Action:   action,
 	}
-	if err := database.DB.Create(&swipe).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record swipe"})
+
+	if req.PromptAnswerID != "" {
+		answerID, err := uuid.Parse(req.PromptAnswerID)
+		if err != nil || !isLike {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid prompt reply"})
+		}
+		comment, err := services.ValidatePromptLike(swipedID, answerID, req.Comment)
+		if err != nil {
+			var validationErr *services.MessageValidationError
+			if errors.As(err, &validationErr) {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Reason})
+			}
+			if errors.Is(err, services.ErrPromptNotFound) {
+				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Prompt answer not found"})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record swipe"})
+		}
+		swipe.PromptAnswerID = &answerID
+		swipe.PromptComment = comment
 	}
 
-	// Check for match (if action is like or super_like)
-	if req.Action == "like" || req.Action == "super_like" {
-		var mutualSwipe models.Swipe
-		if err := database.DB.Where("swiper_id = ? AND swiped_id = ? AND action IN ?", swipedID, swiperID, []string{"like", "super_like"}).First(&mutualSwipe).Error; err == nil {
-			// It's a match!
-			match := models.Match{
-				User1ID:     swiperID,
-				User2ID:     swipedID,
-				InitiatedBy: swiperID,
-				IsActive:    true,
+	var (
+		match          models.Match
+		matched        bool // The pair has an active match after this swipe
+		newMatch       bool // This swipe created the match (or a new epoch of it)
+		rematched      bool // The match existed before and was restarted
+		superLikeCoins int
+		swiper         *models.User
+	)
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Serialise swipes between the same two users
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", swipePairLockKey(swiperID, swipedID)).Error; err != nil {
+			return err
+		}
+
+		// Backed by the unique (swiper_id, swiped_id) index instead of a read-then-write check
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&swipe)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadySwiped
+		}
+
+		// Super likes use the daily quota, then coins
+		if action == models.SwipeActionSuperLike {
+			var err error
+			superLikeCoins, swiper, err = chargeSuperLike(tx, swiperID, swipedID)
+			if err != nil {
+				return err
 			}
-			// Ensure consistent ordering
-			if swiperID.String() > swipedID.String() {
-				match.User1ID, match.User2ID = match.User2ID, match.User1ID
+		}
+
+		if !isLike {
+			return nil
+		}
+
+		var mutual int64
+		if err := tx.Model(&models.Swipe{}).
+			Where("swiper_id = ? AND swiped_id = ? AND action IN ?", swipedID, swiperID, []models.SwipeAction{models.SwipeActionLike, models.SwipeActionSuperLike}).
+			Count(&mutual).Error; err != nil {
+			return err
+		}
+		if mutual == 0 {
+			return nil
+		}
+
+		// It's a match! Upsert on the unique ordered pair (BeforeCreate orders user1 < user2)
+		match = models.Match{
+			User1ID:        swiperID,
+			User2ID:        swipedID,
+			InitiatedBy:    swiperID,
+			IsActive:       true,
+			Status:         models.MatchStatusActive,
+			Epoch:          1,
+			EpochStartedAt: time.Now(),
+		}
+		match.ExpiresAt = services.MatchExpiryFrom(match.EpochStartedAt)
+		result = tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user1_id"}, {Name: "user2_id"}},
+			DoNothing: true,
+		}).Create(&match)
+		if result.Error != nil {
+			return result.Error
+		}
+		newMatch = result.RowsAffected == 1
+
+		if newMatch {
+			if err := services.RecordMatchEvent(tx, &match, models.MatchEventMatched, &swiperID); err != nil {
+				return err
 			}
-			if err := database.DB.Create(&match).Error; err != nil {
-				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create match"})
+		} else {
+			var existing models.Match
+			if err := tx.Where("user1_id = ? AND user2_id = ?", match.User1ID, match.User2ID).First(&existing).Error; err != nil {
+				return err
 			}
+			match = existing
 
-			// Get matched user details for notification
-			var matchedUser models.User
-			database.DB.First(&matchedUser, "id = ?", swipedID)
+			// The pair matched before and it ended: start a new epoch
+			if services.CanRematch(match) {
+				if err := services.Rematch(tx, &match, swiperID); err != nil {
+					return err
+				}
+				newMatch = true
+				rematched = true
+			}
+		}
+		matched = match.IsActive
+		return nil
+	})
+
+	switch {
+	case err == errAlreadySwiped:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Already swiped"})
+	case err == errInsufficientCoins:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    "No free super likes left today",
+			"required": superLikeCost,
+			"balance":  swiper.CoinBalance,
+			"reset_at": addisToday().Add(21 * time.Hour), // Midnight Addis time, in UTC
+		})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record swipe"})
+	}
+	if rematched {
+		services.InvalidateMatchMembers(match.ID)
+	}
+
+	superLikeInfo := fiber.Map{}
+	if swiper != nil {
+		superLikeInfo = fiber.Map{
+			"super_likes_left": superLikesLeft(*swiper),
+			"coins_deducted":   superLikeCoins,
+			"new_balance":      swiper.CoinBalance,
+		}
+	}
+
+	// Pop the card from the swiper's deck so it is not served again
+	if database.RedisClient != nil {
+		if err := services.PopSwipeDeckCard(swiperID, swipedID); err != nil {
+			log.Printf("⚠️ Failed to pop swipe deck card: %v", err)
+		}
+	}
+
+	if !isLike {
+		return c.JSON(fiber.Map{
+			"match":   false,
+			"message": "Swipe recorded",
+		})
+	}
+
+	services.RecordBoostLike(swipedID)
 
-			// Send push notification (async)
+	if matched {
+		// Get matched user details for notification
+		var matchedUser models.User
+		database.DB.First(&matchedUser, "id = ?", swipedID)
+
+		// Send push notification (async), only once per match
+		if newMatch {
 			go func() {
 				if services.NotificationSvc != nil {
 					services.NotificationSvc.NotifyNewMatch(match, matchedUser)
 				}
 			}()
+			// Prompt replies either of them sent with their like open the chat
+			go services.SendPromptReplies(match)
+		}
 
-			return c.JSON(fiber.Map{
-				"match":    true,
-				"match_id": match.ID,
-				"message":  "It's a match! 💚",
-				"user":     matchedUser,
-			})
-		} else {
-			// No match yet, but send "someone liked you" notification if enabled
-			var swipedUser models.User
-			if err := database.DB.First(&swipedUser, "id = ?", swipedID).Error; err == nil {
-				var swiperUser models.User
-				if err := database.DB.First(&swiperUser, "id = ?", swiperID).Error; err == nil {
-					// Send "someone liked you" notification (optional, can be disabled)
-					go func() {
-						if services.NotificationSvc != nil {
-							services.NotificationSvc.NotifySomeoneLiked(swiperUser, swipedID)
-						}
-					}()
-				}
+		return c.JSON(fiber.Map{
+			"match":      true,
+			"match_id":   match.ID,
+			"rematch":    rematched,
+			"message":    "It's a match! 💚",
+			"user":       matchedUser,
+			"super_like": superLikeInfo,
+		})
+	}
+
+	// No match yet, but send "someone liked you" notification if enabled
+	isSuperLike := action == models.SwipeActionSuperLike
+	go func() {
+		if services.NotificationSvc != nil {
+			if isSuperLike {
+				services.NotificationSvc.NotifySuperLiked(swipedID)
+			} else {
+				services.NotificationSvc.NotifySomeoneLiked(swipedID)
+			}
+		}
+	}()
+
+	// Super likers jump to the top of the recipient's deck if they fit the recipient's
+	// preferences (as for boosts) and the recipient has not swiped on them yet
+	if action == models.SwipeActionSuperLike && database.RedisClient != nil {
+		var recipient models.User
+		var eligible int64
+		if err := database.DB.First(&recipient, "id = ?", swipedID).Error; err == nil {
+			swipeCandidateQuery(recipient).Where("users.id = ?", swiperID).Count(&eligible)
+		}
+		if eligible > 0 {
+			if err := services.PromoteSwipeDeckCards(swipedID, []uuid.UUID{swiperID}); err != nil {
+				log.Printf("⚠️ Failed to promote super like: %v", err)
 			}
 		}
 	}
 
 	return c.JSON(fiber.Map{
-		"match":   false,
-		"message": "Swipe recorded",
+		"match":      false,
+		"message":    "Swipe recorded",
+		"super_like": superLikeInfo,
 	})
 }
 
@@ -235,14 +629,28 @@ func GetExploreFeed(c *fiber.Ctx) error {
 	limit := c.QueryInt("limit", 20)
 	offset := (page - 1) * limit
 
+	var currentUser models.User
+	if err := database.DB.First(&currentUser, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	}
+
 	// Get media from active users (excluding current user)
 	var media []models.Media
 	query := database.DB.
 		Joins("JOIN users ON media.user_id = users.id").
-		Where("users.is_active = ?", true).
-		Where("users.id != ?", userID).
-		Where("media.is_approved = ?", true).
-		Order("media.created_at DESC").
+		Scopes(discoveryExclusions(userID)).
+		Where("media.is_approved = ?", true)
+
+	// Boosted profiles in the viewer's city come first
+	if boostedIDs, err := services.ActiveBoostedUsers(currentUser.City); err == nil && len(boostedIDs) > 0 {
+		query = query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:                "CASE WHEN media.user_id IN ? THEN 0 ELSE 1 END",
+			Vars:               []interface{}{boostedIDs},
+			WithoutParentheses: true,
+		}})
+	}
+
+	query = query.Order("media.created_at DESC").
 		Limit(limit).
 		Offset(offset)
 
@@ -269,6 +677,12 @@ func GetExploreFeed(c *fiber.Ctx) error {
 	expiresIn := 24 * time.Hour // URLs valid for 24 hours
 	formattedItems := make([]FormattedFeedItem, 0)
 
+	shownUserIDs := make([]uuid.UUID, 0, len(media))
+	for _, m := range media {
+		shownUserIDs = append(shownUserIDs, m.UserID)
+	}
+	services.RecordBoostViews(shownUserIDs)
+
 	for _, m := range media {
 		var u models.User
 		database.DB.First(&u, "id = ?", m.UserID)
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"lomi-backend/config"
+	"lomi-backend/internal/models"
+	"math"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const earthRadiusKm = 6371.0
+
+// haversineSQL computes the great-circle distance (km) between users.latitude/longitude
+// and a point. Placeholders: lat, lat, lon.
+var haversineSQL = fmt.Sprintf(
+	"(2 * %.1f * ASIN(LEAST(1, SQRT(POWER(SIN(RADIANS(users.latitude - ?) / 2), 2) + "+
+		"COS(RADIANS(?)) * COS(RADIANS(users.latitude)) * POWER(SIN(RADIANS(users.longitude - ?) / 2), 2)))))",
+	earthRadiusKm,
+)
+
+// postgisPointSQL must match the expression of idx_users_geography (migration 007)
+const postgisPointSQL = "geography(ST_MakePoint(users.longitude::float8, users.latitude::float8))"
+
+// Users without coordinates can only be matched by city
+const unlocatedSQL = "((users.latitude IS NULL OR users.longitude IS NULL OR (users.latitude = 0 AND users.longitude = 0)) AND users.city = ?)"
+
+// boundingBox is a lat/lon rectangle that fully contains a circle of a given radius
+type boundingBox struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+// boundingBoxAround returns the box enclosing radiusKm around (lat, lon).
+// Used as a cheap index-backed prefilter before the exact Haversine check.
+func boundingBoxAround(lat, lon, radiusKm float64) boundingBox {
+	latDelta := radiusKm / earthRadiusKm * 180 / math.Pi
+
+	lonDelta := 180.0
+	if cosLat := math.Cos(lat * math.Pi / 180); cosLat > 1e-6 {
+		lonDelta = math.Min(180, latDelta/cosLat)
+	}
+
+	return boundingBox{
+		MinLat: math.Max(-90, lat-latDelta),
+		MaxLat: math.Min(90, lat+latDelta),
+		MinLon: math.Max(-180, lon-lonDelta),
+		MaxLon: math.Min(180, lon+lonDelta),
+	}
+}
+
+// hasLocation reports whether a user has shared coordinates (0,0 is treated as unset)
+func hasLocation(u models.User) bool {
+	return u.Latitude != 0 || u.Longitude != 0
+}
+
+// withinDiscoveryRadius restricts a users query to candidates within radiusKm of the viewer.
+// Candidates without coordinates are still included when they are in the viewer's city,
+// and viewers without coordinates fall back to the city filter.
+func withinDiscoveryRadius(viewer models.User, radiusKm float64) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !hasLocation(viewer) {
+			return db.Where("users.city = ?", viewer.City)
+		}
+
+		if config.Cfg.GeoUsePostGIS {
+			return db.Where(
+				"(ST_DWithin("+postgisPointSQL+", geography(ST_MakePoint(?, ?)), ?) OR "+unlocatedSQL+")",
+				viewer.Longitude, viewer.Latitude, radiusKm*1000, viewer.City,
+			)
+		}
+
+		box := boundingBoxAround(viewer.Latitude, viewer.Longitude, radiusKm)
+		return db.Where(
+			"((users.latitude BETWEEN ? AND ? AND users.longitude BETWEEN ? AND ? AND "+haversineSQL+" <= ?) OR "+unlocatedSQL+")",
+			box.MinLat, box.MaxLat, box.MinLon, box.MaxLon,
+			viewer.Latitude, viewer.Latitude, viewer.Longitude, radiusKm,
+			viewer.City,
+		)
+	}
+}
+
+// orderByDistance sorts candidates nearest first; users without coordinates go last
+func orderByDistance(viewer models.User) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if !hasLocation(viewer) {
+			return db
+		}
+
+		expr := clause.Expr{
+			SQL:                haversineSQL + " ASC NULLS LAST",
+			Vars:               []interface{}{viewer.Latitude, viewer.Latitude, viewer.Longitude},
+			WithoutParentheses: true,
+		}
+		if config.Cfg.GeoUsePostGIS {
+			expr = clause.Expr{
+				SQL:                "ST_Distance(" + postgisPointSQL + ", geography(ST_MakePoint(?, ?))) ASC NULLS LAST",
+				Vars:               []interface{}{viewer.Longitude, viewer.Latitude},
+				WithoutParentheses: true,
+			}
+		}
+		return db.Order(clause.OrderBy{Expression: expr})
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Exclusion scopes shared by every discovery surface (swipe deck, explore feed,
+// pending likes, leaderboard). Each scope takes the SQL column holding the candidate
+// user ID (e.g. "users.id") and filters with NOT EXISTS subqueries, so there are no
+// pre-fetched ID lists and no empty NOT IN (...) clauses.
+
+// discoverableUsers keeps only active, non-deleted users with at least one approved photo.
+// The query must join or select from the users table.
+func discoverableUsers() func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.is_active = ?", true).
+			Where("users.deleted_at IS NULL").
+			Where("EXISTS (SELECT 1 FROM media WHERE media.user_id = users.id AND media.media_type = ? AND media.is_approved = ?)",
+				models.MediaTypePhoto, true)
+	}
+}
+
+// notViewer removes the viewer themselves (deck and feed; the leaderboard keeps them)
+func notViewer(viewerID uuid.UUID, userIDColumn string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(userIDColumn+" != ?", viewerID)
+	}
+}
+
+// hiddenFromViewer removes users the viewer has blocked or been blocked by, former
+// matches unmatched within the rematch cooldown, and users the viewer has reported
+func hiddenFromViewer(viewerID uuid.UUID, userIDColumn string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("NOT EXISTS (SELECT 1 FROM blocks WHERE (blocks.blocker_id = ? AND blocks.blocked_id = "+userIDColumn+") OR (blocks.blocked_id = ? AND blocks.blocker_id = "+userIDColumn+"))",
+				viewerID, viewerID).
+			Where("NOT EXISTS (SELECT 1 FROM matches WHERE matches.status = ? AND matches.unmatched_at > ? AND ((matches.user1_id = ? AND matches.user2_id = "+userIDColumn+") OR (matches.user2_id = ? AND matches.user1_id = "+userIDColumn+")))",
+				models.MatchStatusUnmatched, time.Now().Add(-services.RematchCooldown), viewerID, viewerID).
+			Where("NOT EXISTS (SELECT 1 FROM reports WHERE reports.reporter_id = ? AND reports.reported_user_id = "+userIDColumn+")",
+				viewerID)
+	}
+}
+
+// notSwipedBy removes users the viewer has already swiped on
+func notSwipedBy(viewerID uuid.UUID, userIDColumn string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("NOT EXISTS (SELECT 1 FROM swipes WHERE swipes.swiper_id = ? AND swipes.swiped_id = "+userIDColumn+")",
+			viewerID)
+	}
+}
+
+// discoveryExclusions applies every exclusion rule for viewer to a query over users
+func discoveryExclusions(viewerID uuid.UUID) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// Applied directly: scopes added from inside a scope are not executed by gorm
+		return notViewer(viewerID, "users.id")(hiddenFromViewer(viewerID, "users.id")(discoverableUsers()(db)))
+	}
+}
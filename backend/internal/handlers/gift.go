@@ -131,6 +131,7 @@ func SendGift(c *fiber.Ctx) error {
 	}
 
 	// If sent in chat, create a message
+	var giftMessage *models.Message
 	if req.MatchID != "" {
 		matchID, _ := uuid.Parse(req.MatchID)
 		message := models.Message{
@@ -143,11 +144,17 @@ func SendGift(c *fiber.Ctx) error {
 		if err := tx.Create(&message).Error; err == nil {
 			giftTransaction.MessageID = &message.ID
 			tx.Save(&giftTransaction)
+			giftMessage = &message
 		}
 	}
 
 	tx.Commit()
 
+	// Show the gift in the open chat (the gift push below covers offline receivers)
+	if giftMessage != nil {
+		services.PublishChatMessage(*giftMessage)
+	}
+
 	// Send push notification (async)
 	go func() {
 		if services.NotificationSvc != nil {
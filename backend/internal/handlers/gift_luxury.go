@@ -279,6 +279,7 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 	}
 
 	// If sent in chat, create a message
+	var giftMessage *models.Message
 	if req.MatchID != "" {
 		matchID, _ := uuid.Parse(req.MatchID)
 		message := models.Message{
@@ -290,11 +291,17 @@ func SendGiftLuxury(c *fiber.Ctx) error {
 		if err := tx.Create(&message).Error; err == nil {
 			giftTransaction.MessageID = &message.ID
 			tx.Save(&giftTransaction)
+			giftMessage = &message
 		}
 	}
 
 	tx.Commit()
 
+	// Show the gift in the open chat (the gift push below covers offline receivers)
+	if giftMessage != nil {
+		services.PublishChatMessage(*giftMessage)
+	}
+
 	// Send push notification (async)
 	go func() {
 		if services.NotificationSvc != nil {
@@ -5,10 +5,17 @@ import (
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // GetTopGiftedUsers returns leaderboard of users who received the most gifts
 func GetTopGiftedUsers(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
 	timeframe := c.Query("timeframe", "week") // week, month, all
 	limit := c.QueryInt("limit", 20)
 
@@ -46,6 +53,8 @@ func GetTopGiftedUsers(c *fiber.Ctx) error {
 		`).
 		Joins("JOIN users ON gift_transactions.receiver_id = users.id").
 		Joins("LEFT JOIN media ON users.id = media.user_id AND media.media_type = 'photo' AND media.display_order = 1 AND media.is_approved = true").
+		// Not discoveryExclusions: the viewer should see their own rank
+		Scopes(discoverableUsers(), hiddenFromViewer(userID, "users.id")).
 		Group("users.id, users.name, media.url")
 
 	if !startDate.IsZero() {
@@ -1,109 +1,240 @@
 package handlers
 
 import (
+	"errors"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
 	"lomi-backend/internal/services"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetPendingLikes returns users who liked the current user but haven't been liked back
+const (
+	revealOneCost            = 99  // Coins to reveal one liker
+	revealAllCost            = 299 // Coins to reveal everyone pending
+	pendingLikesDefaultLimit = 50
+	pendingLikesMaxLimit     = 100
+)
+
+var (
+	errNoPendingLikes = errors.New("no pending likes to reveal")
+	errNotPendingLike = errors.New("like not in pending likes")
+)
+
+// pendingLikes selects inbound likes the user has not answered yet, one row per liker
+// (swipes are unique per pair), with the liker's reveal from the like_reveals ledger.
+// Likers hidden from discovery (blocked, reported, deactivated) are left out.
+func pendingLikes(db *gorm.DB, userID uuid.UUID) *gorm.DB {
+	return db.Table("swipes likes").
+		Joins("JOIN users ON users.id = likes.swiper_id").
+		Joins("LEFT JOIN like_reveals ON like_reveals.user_id = ? AND like_reveals.liker_id = likes.swiper_id", userID).
+		Where("likes.swiped_id = ? AND likes.action IN ?", userID,
+			[]models.SwipeAction{models.SwipeActionLike, models.SwipeActionSuperLike}).
+		Scopes(discoveryExclusions(userID), notSwipedBy(userID, "users.id"))
+}
+
+// Super likes first, then most recent
+const pendingLikesOrder = "likes.action = 'super_like' DESC, likes.created_at DESC, likes.id DESC"
+
+// hasFreeReveal reports whether the user's daily free reveal is unused
+func hasFreeReveal(u models.User) bool {
+	if u.LastRevealDate.IsZero() || u.LastRevealDate.Before(addisToday()) {
+		return true
+	}
+	return !u.DailyFreeRevealUsed
+}
+
+// LikePreview is all an unrevealed liker shows: enough to tempt, not to identify
+type LikePreview struct {
+	Name       string `json:"name"` // Initial only
+	Age        int    `json:"age"`
+	City       string `json:"city"`
+	IsVerified bool   `json:"is_verified"`
+	Photo      string `json:"photo,omitempty"` // A few pixels wide (data URI); scale up behind a blur
+}
+
+// PendingLike is one liker on "Who Likes You". User is set once revealed, Preview until then.
+// LikeID (the swipe) is the handle for revealing: the liker's user ID is only sent with User.
+type PendingLike struct {
+	LikeID      uuid.UUID    `json:"like_id"`
+	User        *models.User `json:"user,omitempty"`
+	Preview     *LikePreview `json:"preview,omitempty"`
+	LikedAt     time.Time    `json:"liked_at"`
+	IsSuperLike bool         `json:"is_super_like"`
+	IsRevealed  bool         `json:"is_revealed"`
+	RevealedAt  *time.Time   `json:"revealed_at,omitempty"`
+	Comment     string       `json:"comment,omitempty"` // Prompt reply sent with the like (revealed only)
+}
+
+type pendingLikeRow struct {
+	models.User       `gorm:"embedded"`
+	LikeID            uuid.UUID
+	LikedAt           time.Time
+	IsSuperLike       bool
+	RevealedAt        *time.Time
+	PromptComment     string
+	PhotoID           *uuid.UUID
+	PhotoKey          string
+	PhotoThumbnailKey string
+	PhotoPreview      string
+}
+
+// likeRef pairs a pending like with its liker
+type likeRef struct {
+	LikeID  uuid.UUID
+	LikerID uuid.UUID
+}
+
+// parsePendingLikesCursor reads "super|created_at|like_id" as written by GetPendingLikes
+func parsePendingLikesCursor(cursor string) (bool, time.Time, uuid.UUID, bool) {
+	parts := strings.SplitN(cursor, "|", 3)
+	if len(parts) != 3 {
+		return false, time.Time{}, uuid.Nil, false
+	}
+	super, err := strconv.ParseBool(parts[0])
+	if err != nil {
+		return false, time.Time{}, uuid.Nil, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return false, time.Time{}, uuid.Nil, false
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return false, time.Time{}, uuid.Nil, false
+	}
+	return super, at, id, true
+}
+
+// GetPendingLikes returns users who liked the current user and haven't been swiped on back,
+// super likes first. Revealed likers come with their profile, the rest with a blurred
+// preview. Paginate with ?limit= and ?cursor= (next_cursor from the previous page).
 func GetPendingLikes(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
 	userIDStr := claims["user_id"].(string)
 	userID, _ := uuid.Parse(userIDStr)
 
-	// Get users who liked me (swiped on me with like/super_like)
-	var swipes []models.Swipe
-	database.DB.Where("swiped_id = ? AND action IN ?", userID, []models.SwipeAction{models.SwipeActionLike, models.SwipeActionSuperLike}).
-		Order("created_at DESC").
-		Find(&swipes)
-
-	// Check if I've liked them back (if so, they're a match, not a pending like)
-	var myLikes []uuid.UUID
-	database.DB.Model(&models.Swipe{}).
-		Where("swiper_id = ? AND swiped_id IN (SELECT swiper_id FROM swipes WHERE swiped_id = ? AND action IN ?)", userID, userID, []models.SwipeAction{models.SwipeActionLike, models.SwipeActionSuperLike}).
-		Pluck("swiped_id", &myLikes)
-
-	// Filter out users I've already liked back
-	pendingLikerIDs := make([]uuid.UUID, 0)
-	for _, swipe := range swipes {
-		alreadyLiked := false
-		for _, likedID := range myLikes {
-			if swipe.SwiperID == likedID {
-				alreadyLiked = true
-				break
-			}
-		}
-		if !alreadyLiked {
-			pendingLikerIDs = append(pendingLikerIDs, swipe.SwiperID)
-		}
+	var currentUser models.User
+	if err := database.DB.First(&currentUser, "id = ?", userID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
 	}
 
-	// Get user details for pending likers
-	var pendingUsers []models.User
-	if len(pendingLikerIDs) > 0 {
-		database.DB.Where("id IN ? AND is_active = ?", pendingLikerIDs, true).Find(&pendingUsers)
+	limit := c.QueryInt("limit", pendingLikesDefaultLimit)
+	if limit <= 0 || limit > pendingLikesMaxLimit {
+		limit = pendingLikesDefaultLimit
 	}
 
-	// Get swipe timestamps for each user
-	type PendingLike struct {
-		User       models.User `json:"user"`
-		LikedAt    time.Time   `json:"liked_at"`
-		IsRevealed bool        `json:"is_revealed"` // For future: track if user has revealed this person
+	query := pendingLikes(database.DB, userID).
+		Select(`users.*,
+			likes.id AS like_id,
+			likes.created_at AS liked_at,
+			likes.action = 'super_like' AS is_super_like,
+			like_reveals.created_at AS revealed_at,
+			likes.prompt_comment AS prompt_comment,
+			photo.id AS photo_id,
+			photo.url AS photo_key,
+			photo.thumbnail_url AS photo_thumbnail_key,
+			photo.blur_preview AS photo_preview`).
+		Joins(`LEFT JOIN LATERAL (
+			SELECT media.id, media.url, media.thumbnail_url, media.blur_preview FROM media
+			WHERE media.user_id = likes.swiper_id AND media.media_type = ? AND media.is_approved = ?
+			ORDER BY media.display_order ASC LIMIT 1
+		) photo ON like_reveals.id IS NULL`, models.MediaTypePhoto, true)
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		super, at, id, ok := parsePendingLikesCursor(cursor)
+		if !ok {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid cursor"})
+		}
+		query = query.Where("(likes.action = 'super_like') < ? OR ((likes.action = 'super_like') = ? AND (likes.created_at, likes.id) < (?, ?))",
+			super, super, at, id)
 	}
 
-	pendingLikes := make([]PendingLike, 0)
-	for _, u := range pendingUsers {
-		// Find the swipe timestamp
-		var swipe models.Swipe
-		database.DB.Where("swiper_id = ? AND swiped_id = ?", u.ID, userID).
-			Order("created_at DESC").
-			First(&swipe)
-
-		pendingLikes = append(pendingLikes, PendingLike{
-			User:       u,
-			LikedAt:    swipe.CreatedAt,
-			IsRevealed: false, // TODO: Track reveals in a separate table if needed
-		})
+	var rows []pendingLikeRow
+	if err := query.Order(pendingLikesOrder).Limit(limit).Scan(&rows).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch likes"})
 	}
 
-	// Get current user's daily free reveal status
-	var currentUser models.User
-	database.DB.First(&currentUser, "id = ?", userID)
-
-	// Check if free reveal resets (Addis time is UTC+3)
-	now := time.Now()
-	addisTime := now.UTC().Add(3 * time.Hour)
-	today := time.Date(addisTime.Year(), addisTime.Month(), addisTime.Day(), 0, 0, 0, 0, time.UTC)
-
-	hasFreeReveal := false
-	if currentUser.LastRevealDate.IsZero() || currentUser.LastRevealDate.Before(today) {
-		hasFreeReveal = true
-	} else {
-		hasFreeReveal = !currentUser.DailyFreeRevealUsed
+	var totals struct {
+		Total      int64
+		Unrevealed int64
+	}
+	if err := pendingLikes(database.DB, userID).
+		Select("COUNT(*) AS total, COUNT(*) FILTER (WHERE like_reveals.id IS NULL) AS unrevealed").
+		Scan(&totals).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch likes"})
 	}
 
-	// Calculate reset time (midnight Addis time)
-	resetTime := today.Add(24 * time.Hour)
-	if addisTime.Hour() >= 0 && addisTime.Hour() < 3 {
-		resetTime = today // If before 3 AM UTC, reset is today
+	// Previews for unrevealed likers, made on first use
+	photos := make([]services.LikePreviewPhoto, len(rows))
+	for i, row := range rows {
+		if row.RevealedAt == nil && row.PhotoID != nil {
+			photos[i] = services.LikePreviewPhoto{
+				MediaID:      *row.PhotoID,
+				Key:          row.PhotoKey,
+				ThumbnailKey: row.PhotoThumbnailKey,
+				Preview:      row.PhotoPreview,
+			}
+		}
+	}
+	previews := services.LikePreviews(photos)
+
+	likes := make([]PendingLike, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		like := PendingLike{
+			LikeID:      row.LikeID,
+			LikedAt:     row.LikedAt,
+			IsSuperLike: row.IsSuperLike,
+			IsRevealed:  row.RevealedAt != nil,
+			RevealedAt:  row.RevealedAt,
+		}
+		if like.IsRevealed {
+			like.User = &row.User
+			like.Comment = row.PromptComment
+		} else {
+			initial := ""
+			if r := []rune(strings.TrimSpace(row.Name)); len(r) > 0 {
+				initial = string(r[0])
+			}
+			like.Preview = &LikePreview{
+				Name:       initial,
+				Age:        row.Age,
+				City:       row.City,
+				IsVerified: row.IsVerified,
+				Photo:      previews[i],
+			}
+		}
+		likes = append(likes, like)
 	}
 
-	return c.JSON(fiber.Map{
-		"pending_likes":   pendingLikes,
-		"count":           len(pendingLikes),
-		"has_free_reveal": hasFreeReveal,
-		"reset_at":        resetTime,
-	})
+	response := fiber.Map{
+		"pending_likes":    likes,
+		"count":            totals.Total,
+		"unrevealed_count": totals.Unrevealed,
+		"has_free_reveal":  hasFreeReveal(currentUser),
+		"reset_at":         addisToday().Add(21 * time.Hour), // Midnight Addis time, in UTC
+	}
+	if len(rows) == limit {
+		last := rows[len(rows)-1]
+		response["next_cursor"] = strconv.FormatBool(last.IsSuperLike) + "|" +
+			last.LikedAt.UTC().Format(time.RFC3339Nano) + "|" + last.LikeID.String()
+	}
+	return c.JSON(response)
 }
 
-// RevealLike handles revealing a pending like (free or paid)
+// RevealLike unlocks pending likers and records them in the like_reveals ledger. The daily
+// free reveal covers one liker; otherwise one costs revealOneCost and everyone pending
+// costs revealAllCost, or less if revealing them one by one would be cheaper. Revealing a liker who is already unlocked is free. Likers are picked
+// by like_id from GetPendingLikes, so their user ID is not known before paying.
 func RevealLike(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
 	claims := user.Claims.(jwt.MapClaims)
@@ -111,172 +242,174 @@ func RevealLike(c *fiber.Ctx) error {
 	userID, _ := uuid.Parse(userIDStr)
 
 	var req struct {
-		RevealAll bool   `json:"reveal_all"` // If true, reveal all for 299 coins
-		TargetID  string `json:"target_id"`  // If reveal_all is false, reveal this specific user for 99 coins
+		RevealAll bool   `json:"reveal_all"` // If true, reveal everyone pending for 299 coins
+		LikeID    string `json:"like_id"`    // If reveal_all is false, reveal this like (or the top liker) for 99 coins
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	var currentUser models.User
-	if err := database.DB.First(&currentUser, "id = ?", userID).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	var targetID uuid.UUID
+	if !req.RevealAll && req.LikeID != "" {
+		var err error
+		if targetID, err = uuid.Parse(req.LikeID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid like ID"})
+		}
 	}
 
-	// Get pending likes
-	var swipes []models.Swipe
-	database.DB.Where("swiped_id = ? AND action IN ?", userID, []models.SwipeAction{models.SwipeActionLike, models.SwipeActionSuperLike}).
-		Order("created_at DESC").
-		Find(&swipes)
-
-	// Filter out users already liked back
-	var myLikes []uuid.UUID
-	database.DB.Model(&models.Swipe{}).
-		Where("swiper_id = ?", userID).
-		Pluck("swiped_id", &myLikes)
-
-	pendingLikerIDs := make([]uuid.UUID, 0)
-	for _, swipe := range swipes {
-		alreadyLiked := false
-		for _, likedID := range myLikes {
-			if swipe.SwiperID == likedID {
-				alreadyLiked = true
-				break
+	var (
+		currentUser models.User
+		cost        int
+		revealed    []likeRef
+		newReveals  []likeRef
+	)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so concurrent reveals cannot both use the free reveal or overdraw coins
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&currentUser, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		candidates := pendingLikes(tx, userID).Where("like_reveals.id IS NULL")
+		if targetID != uuid.Nil {
+			if err := pendingLikes(tx, userID).Where("likes.id = ? AND like_reveals.id IS NOT NULL", targetID).
+				Select("likes.id AS like_id, likes.swiper_id AS liker_id").Scan(&revealed).Error; err != nil {
+				return err
+			}
+			if len(revealed) > 0 {
+				return nil
 			}
+			candidates = candidates.Where("likes.id = ?", targetID)
 		}
-		if !alreadyLiked {
-			pendingLikerIDs = append(pendingLikerIDs, swipe.SwiperID)
+		if !req.RevealAll {
+			candidates = candidates.Limit(1)
 		}
-	}
-
-	if len(pendingLikerIDs) == 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No pending likes to reveal"})
-	}
-
-	// Check daily free reveal status
-	now := time.Now()
-	addisTime := now.UTC().Add(3 * time.Hour)
-	today := time.Date(addisTime.Year(), addisTime.Month(), addisTime.Day(), 0, 0, 0, 0, time.UTC)
-
-	hasFreeReveal := false
-	if currentUser.LastRevealDate.IsZero() || currentUser.LastRevealDate.Before(today) {
-		hasFreeReveal = true
-	} else {
-		hasFreeReveal = !currentUser.DailyFreeRevealUsed
-	}
-
-	// Reset daily free reveal if it's a new day
-	if currentUser.LastRevealDate.IsZero() || currentUser.LastRevealDate.Before(today) {
-		currentUser.DailyFreeRevealUsed = false
-		currentUser.LastRevealDate = today
-	}
-
-	var cost int
-	var revealedIDs []uuid.UUID
-
-	if req.RevealAll {
-		// Reveal all for 299 coins (or free if first reveal of day)
-		if hasFreeReveal {
-			// First reveal is free, rest cost 299
-			if len(pendingLikerIDs) > 1 {
-				cost = 299
-			} else {
-				cost = 0 // Only one like, use free reveal
+		if err := candidates.Select("likes.id AS like_id, likes.swiper_id AS liker_id").
+			Order(pendingLikesOrder).Scan(&newReveals).Error; err != nil {
+			return err
+		}
+		if len(newReveals) == 0 {
+			if targetID != uuid.Nil {
+				return errNotPendingLike
 			}
-			revealedIDs = pendingLikerIDs
-		} else {
-			cost = 299
-			revealedIDs = pendingLikerIDs
+			return errNoPendingLikes
 		}
-	} else {
-		// Reveal one for 99 coins (or free if first reveal of day)
-		if hasFreeReveal {
+		revealed = newReveals
+
+		free := hasFreeReveal(currentUser)
+		source := models.LikeRevealSourceSingle
+		switch {
+		case free && len(newReveals) == 1:
 			cost = 0
-		} else {
-			cost = 99
+			source = models.LikeRevealSourceFree
+		case req.RevealAll && len(newReveals) > 1:
+			// Never more than revealing each of them one by one
+			cost = min(revealAllCost, len(newReveals)*revealOneCost)
+			source = models.LikeRevealSourceAll
+		default:
+			cost = revealOneCost
+		}
+
+		if cost > currentUser.CoinBalance {
+			return errInsufficientCoins
 		}
 
-		if req.TargetID != "" {
-			targetID, err := uuid.Parse(req.TargetID)
-			if err != nil {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid target ID"})
+		updates := map[string]interface{}{}
+		var transactionID *uuid.UUID
+		if cost > 0 {
+			currentUser.CoinBalance -= cost
+			updates["coin_balance"] = currentUser.CoinBalance
+
+			likerIDs := make([]string, len(newReveals))
+			for i, ref := range newReveals {
+				likerIDs[i] = ref.LikerID.String()
 			}
-			// Verify target is in pending likes
-			found := false
-			for _, id := range pendingLikerIDs {
-				if id == targetID {
-					found = true
-					revealedIDs = []uuid.UUID{targetID}
-					break
-				}
+			transaction := models.CoinTransaction{
+				UserID:          userID,
+				TransactionType: models.TransactionTypeReveal,
+				CoinAmount:      -cost,
+				BalanceAfter:    currentUser.CoinBalance,
+				Metadata: models.JSONMap{
+					"reveal_all":     req.RevealAll,
+					"revealed_count": len(newReveals),
+					"liker_ids":      likerIDs,
+				},
 			}
-			if !found {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Target user not in pending likes"})
+			if err := tx.Create(&transaction).Error; err != nil {
+				return err
 			}
+			transactionID = &transaction.ID
 		} else {
-			// Reveal random one
-			if len(pendingLikerIDs) > 0 {
-				revealedIDs = []uuid.UUID{pendingLikerIDs[0]} // Take first (most recent)
+			currentUser.DailyFreeRevealUsed = true
+			currentUser.LastRevealDate = addisToday()
+			updates["daily_free_reveal_used"] = true
+			updates["last_reveal_date"] = currentUser.LastRevealDate
+		}
+
+		if err := tx.Model(&currentUser).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		reveals := make([]models.LikeReveal, len(newReveals))
+		for i, ref := range newReveals {
+			reveals[i] = models.LikeReveal{
+				UserID:            userID,
+				LikerID:           ref.LikerID,
+				Source:            source,
+				CoinTransactionID: transactionID,
 			}
 		}
-	}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&reveals).Error
+	})
 
-	// Check coin balance
-	if cost > 0 && currentUser.CoinBalance < cost {
+	switch {
+	case errors.Is(err, errInsufficientCoins):
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":    "Insufficient coins",
 			"required": cost,
 			"balance":  currentUser.CoinBalance,
 		})
+	case errors.Is(err, errNoPendingLikes):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "No pending likes to reveal"})
+	case errors.Is(err, errNotPendingLike):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Like not in pending likes"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to reveal"})
 	}
 
-	// Deduct coins
-	if cost > 0 {
-		currentUser.CoinBalance -= cost
-
-		// Create coin transaction record
-		transaction := models.CoinTransaction{
-			UserID:          userID,
-			TransactionType: models.TransactionTypeReveal,
-			CoinAmount:      -cost,
-			BalanceAfter:    currentUser.CoinBalance,
-			Metadata: models.JSONMap{
-				"reveal_type": map[string]interface{}{
-					"reveal_all":     req.RevealAll,
-					"revealed_count": len(revealedIDs),
-				},
-			},
-		}
-		database.DB.Create(&transaction)
+	likerIDs := make([]uuid.UUID, len(revealed))
+	for i, ref := range revealed {
+		likerIDs[i] = ref.LikerID
 	}
+	var revealedUsers []models.User
+	database.DB.Where("id IN ?", likerIDs).Find(&revealedUsers)
 
-	// Update daily free reveal status
-	if hasFreeReveal && cost == 0 {
-		currentUser.DailyFreeRevealUsed = true
-		currentUser.LastRevealDate = today
+	usersByID := make(map[uuid.UUID]models.User, len(revealedUsers))
+	for _, u := range revealedUsers {
+		usersByID[u.ID] = u
 	}
-
-	database.DB.Save(&currentUser)
-
-	// Get revealed users
-	var revealedUsers []models.User
-	if len(revealedIDs) > 0 {
-		database.DB.Where("id IN ?", revealedIDs).Find(&revealedUsers)
+	revealedLikes := make([]fiber.Map, 0, len(revealed))
+	for _, ref := range revealed {
+		if u, ok := usersByID[ref.LikerID]; ok {
+			revealedLikes = append(revealedLikes, fiber.Map{"like_id": ref.LikeID, "user": u})
+		}
 	}
 
-	// Send push notifications to revealed users
+	// Let newly revealed likers know someone looked at them
 	go func() {
 		if services.NotificationSvc != nil {
-			for _, revealedUser := range revealedUsers {
-				services.NotificationSvc.NotifySomeoneViewedProfile(revealedUser.ID, userID)
+			for _, ref := range newReveals {
+				services.NotificationSvc.NotifySomeoneViewedProfile(ref.LikerID, userID)
 			}
 		}
 	}()
 
 	return c.JSON(fiber.Map{
+		"revealed_likes":  revealedLikes,
 		"revealed_users":  revealedUsers,
 		"coins_deducted":  cost,
 		"new_balance":     currentUser.CoinBalance,
-		"has_free_reveal": false, // After reveal, no more free reveal today
+		"has_free_reveal": hasFreeReveal(currentUser),
 	})
 }
@@ -1,13 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"lomi-backend/config"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // GetMatches returns all active matches for the current user
@@ -32,6 +37,7 @@ func GetMatches(c *fiber.Ctx) error {
 		User      models.User `json:"user"`
 		CreatedAt string      `json:"created_at"`
 		LastMessage *models.Message `json:"last_message,omitempty"`
+		ExpiresAt *time.Time `json:"expires_at,omitempty"` // Set until someone sends the first message
 	}
 
 	response := make([]MatchResponse, 0)
@@ -45,7 +51,7 @@ func GetMatches(c *fiber.Ctx) error {
 
 		// Get last message
 		var lastMessage models.Message
-		database.DB.Where("match_id = ?", match.ID).
+		database.DB.Where("match_id = ? AND epoch = ?", match.ID, match.Epoch).
 			Order("created_at DESC").
 			First(&lastMessage)
 
@@ -54,6 +60,7 @@ func GetMatches(c *fiber.Ctx) error {
 			User:      otherUser,
 			CreatedAt: match.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 			LastMessage: &lastMessage,
+			ExpiresAt: pendingExpiry(match),
 		})
 	}
 
@@ -98,6 +105,115 @@ func GetMatchDetails(c *fiber.Ctx) error {
 	})
 }
 
+// pendingExpiry returns when a match expires, or nil once someone has written
+func pendingExpiry(match models.Match) *time.Time {
+	if match.FirstMessageAt != nil {
+		return nil
+	}
+	return match.ExpiresAt
+}
+
+// errMatchNotExtendable is returned when the match got a first message or ended meanwhile
+var errMatchNotExtendable = errors.New("match can no longer be extended")
+
+// ExtendMatch spends coins to push back the expiry of an unanswered match
+func ExtendMatch(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	cost := config.Cfg.MatchExtendCost
+	extension := time.Duration(config.Cfg.MatchExtendHours) * time.Hour
+
+	matchID := c.Params("id")
+	var match models.Match
+	if err := database.DB.Where("id = ? AND (user1_id = ? OR user2_id = ?) AND is_active = ?", matchID, userID, userID, true).
+		First(&match).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Match not found"})
+	}
+
+	if pendingExpiry(match) == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This match does not expire"})
+	}
+
+	var (
+		currentUser models.User
+		newExpiry   time.Time
+	)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so concurrent coin spends cannot overdraw or lose a deduction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&currentUser, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if currentUser.CoinBalance < cost {
+			return errInsufficientCoins
+		}
+
+		// Extend from the later of now and the current expiry; re-checked under the row lock
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ? AND first_message_at IS NULL", match.ID, models.MatchStatusActive).
+			First(&match).Error; err != nil {
+			return errMatchNotExtendable
+		}
+
+		base := time.Now()
+		if match.ExpiresAt != nil && match.ExpiresAt.After(base) {
+			base = *match.ExpiresAt
+		}
+		newExpiry = base.Add(extension)
+
+		if err := tx.Model(&match).Updates(map[string]interface{}{
+			"expires_at":           newExpiry,
+			"expiry_reminder_sent": false,
+			"extension_count":      gorm.Expr("extension_count + 1"),
+		}).Error; err != nil {
+			return err
+		}
+
+		// Deduct coins
+		currentUser.CoinBalance -= cost
+		if err := tx.Model(&currentUser).Update("coin_balance", currentUser.CoinBalance).Error; err != nil {
+			return err
+		}
+
+		transaction := models.CoinTransaction{
+			UserID:          userID,
+			TransactionType: models.TransactionTypeMatchExtend,
+			CoinAmount:      -cost,
+			BalanceAfter:    currentUser.CoinBalance,
+			Metadata: models.JSONMap{
+				"match_id":   match.ID.String(),
+				"expires_at": newExpiry,
+			},
+		}
+		return tx.Create(&transaction).Error
+	})
+
+	switch {
+	case errors.Is(err, errInsufficientCoins):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    "Insufficient coins",
+			"required": cost,
+			"balance":  currentUser.CoinBalance,
+		})
+	case errors.Is(err, errMatchNotExtendable):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "This match can no longer be extended"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to extend match"})
+	}
+
+	return c.JSON(fiber.Map{
+		"message":        "Match extended",
+		"match_id":       match.ID,
+		"expires_at":     newExpiry,
+		"coins_deducted": cost,
+		"new_balance":    currentUser.CoinBalance,
+	})
+}
+
 // Unmatch removes a match
 func Unmatch(c *fiber.Ctx) error {
 	user := c.Locals("user").(*jwt.Token)
@@ -112,15 +228,59 @@ func Unmatch(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Match not found"})
 	}
 
-	now := time.Now()
-	match.IsActive = false
-	match.UnmatchedBy = &userID
-	match.UnmatchedAt = &now
-
-	if err := database.DB.Save(&match).Error; err != nil {
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return services.EndMatch(tx, &match, models.MatchStatusUnmatched, &userID)
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to unmatch"})
 	}
+	services.InvalidateMatchMembers(match.ID)
 
 	return c.JSON(fiber.Map{"message": "Unmatched successfully"})
 }
 
+
+// GetUnmatchedHistory returns the people the current user unmatched, and when
+func GetUnmatchedHistory(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	var events []models.MatchEvent
+	if err := database.DB.Where("actor_id = ? AND event_type = ?", userID, models.MatchEventUnmatched).
+		Preload("Match.User1").
+		Preload("Match.User2").
+		Order("created_at DESC").
+		Find(&events).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch unmatch history"})
+	}
+
+	type UnmatchedResponse struct {
+		MatchID       uuid.UUID          `json:"match_id"`
+		User          models.User        `json:"user"`
+		UnmatchedAt   time.Time          `json:"unmatched_at"`
+		Epoch         int                `json:"epoch"`
+		CurrentStatus models.MatchStatus `json:"current_status"` // e.g. active again after a rematch
+	}
+
+	response := make([]UnmatchedResponse, 0, len(events))
+	for _, event := range events {
+		otherUser := event.Match.User1
+		if event.Match.User1ID == userID {
+			otherUser = event.Match.User2
+		}
+
+		response = append(response, UnmatchedResponse{
+			MatchID:       event.MatchID,
+			User:          otherUser,
+			UnmatchedAt:   event.CreatedAt,
+			Epoch:         event.Epoch,
+			CurrentStatus: event.Match.Status,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"unmatched": response,
+		"count":     len(response),
+	})
+}
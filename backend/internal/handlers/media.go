@@ -87,14 +87,52 @@ func UploadMedia(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(media)
 }
 
+// canViewUserMedia reports whether the viewer may see a user's full media: their own,
+// a match's (current or past) or a liker they revealed. Other likers only get the blurred
+// preview from GetPendingLikes.
+func canViewUserMedia(viewerID, userID uuid.UUID) (bool, error) {
+	if viewerID == userID {
+		return true, nil
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Match{}).
+		Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)", viewerID, userID, userID, viewerID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+
+	if err := database.DB.Model(&models.LikeReveal{}).
+		Where("user_id = ? AND liker_id = ?", viewerID, userID).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 // GetUserMedia returns all media for a user with pre-signed download URLs
 func GetUserMedia(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	viewerID, _ := uuid.Parse(claims["user_id"].(string))
+
 	userIDParam := c.Params("user_id")
 	userID, err := uuid.Parse(userIDParam)
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	allowed, err := canViewUserMedia(viewerID, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch media"})
+	}
+	if !allowed {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Media is not available for this user"})
+	}
+
 	var photos []models.Media
 	var videos []models.Media
 
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"log"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// messageActionParams reads the user, match and message of a /chats/:id/messages/:message_id request
+func messageActionParams(c *fiber.Ctx) (userID, matchID, messageID uuid.UUID, err error) {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ = uuid.Parse(userIDStr)
+
+	if matchID, err = uuid.Parse(c.Params("id")); err != nil {
+		return
+	}
+	messageID, err = uuid.Parse(c.Params("message_id"))
+	return
+}
+
+func messageActionError(c *fiber.Ctx, err error, fallback string) error {
+	status, reason := messageError(err)
+	if status == fiber.StatusInternalServerError {
+		log.Printf("❌ %s: %v", fallback, err)
+		reason = fallback
+	}
+	return c.Status(status).JSON(fiber.Map{"error": reason})
+}
+
+// EditMessage changes the text (or caption) of a message the user sent, shortly after sending
+// PUT /chats/:id/messages/:message_id {"content": "..."}
+func EditMessage(c *fiber.Ctx) error {
+	userID, matchID, messageID, err := messageActionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match or message ID"})
+	}
+
+	var req struct {
+		Content string `json:"content"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	message, err := services.EditMessage(userID, matchID, messageID, req.Content)
+	if err != nil {
+		return messageActionError(c, err, "Failed to edit message")
+	}
+
+	message.MediaURL = services.ChatMediaURL(message.MediaURL)
+	return c.JSON(message)
+}
+
+// GetMessageEdits returns the earlier versions of an edited message
+// GET /chats/:id/messages/:message_id/edits
+func GetMessageEdits(c *fiber.Ctx) error {
+	userID, matchID, messageID, err := messageActionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match or message ID"})
+	}
+
+	edits, err := services.MessageEditHistory(userID, matchID, messageID)
+	if err != nil {
+		return messageActionError(c, err, "Failed to fetch edit history")
+	}
+
+	return c.JSON(fiber.Map{"edits": edits})
+}
+
+// DeleteMessage deletes a message for the user only (?scope=me, default) or, for the
+// sender, for everyone (?scope=everyone), leaving a tombstone
+// DELETE /chats/:id/messages/:message_id?scope=everyone
+func DeleteMessage(c *fiber.Ctx) error {
+	userID, matchID, messageID, err := messageActionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match or message ID"})
+	}
+
+	scope := services.MessageDeleteScope(c.Query("scope", string(services.MessageDeleteForMe)))
+	if _, err := services.DeleteMessage(userID, matchID, messageID, scope); err != nil {
+		return messageActionError(c, err, "Failed to delete message")
+	}
+
+	return c.JSON(fiber.Map{"message": "Message deleted", "scope": scope})
+}
+
+// ReactToMessage sets the user's emoji reaction on a message, replacing an earlier one
+// PUT /chats/:id/messages/:message_id/reaction {"emoji": "❤️"}
+func ReactToMessage(c *fiber.Ctx) error {
+	userID, matchID, messageID, err := messageActionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match or message ID"})
+	}
+
+	var req struct {
+		Emoji string `json:"emoji"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.Emoji == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "emoji is required"})
+	}
+
+	reactions, err := services.ReactToMessage(userID, matchID, messageID, req.Emoji)
+	if err != nil {
+		return messageActionError(c, err, "Failed to react to message")
+	}
+
+	return c.JSON(fiber.Map{"reactions": reactions})
+}
+
+// RemoveReaction removes the user's reaction from a message
+// DELETE /chats/:id/messages/:message_id/reaction
+func RemoveReaction(c *fiber.Ctx) error {
+	userID, matchID, messageID, err := messageActionParams(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match or message ID"})
+	}
+
+	reactions, err := services.ReactToMessage(userID, matchID, messageID, "")
+	if err != nil {
+		return messageActionError(c, err, "Failed to remove reaction")
+	}
+
+	return c.JSON(fiber.Map{"reactions": reactions})
+}
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"errors"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// GetPrompts returns the prompt catalogue users can answer
+// GET /prompts
+func GetPrompts(c *fiber.Ctx) error {
+	prompts, err := services.ActivePrompts()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch prompts"})
+	}
+	return c.JSON(fiber.Map{
+		"prompts":     prompts,
+		"max_answers": services.MaxProfilePrompts,
+	})
+}
+
+// GetMyPrompts returns the current user's prompt answers
+// GET /users/me/prompts
+func GetMyPrompts(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	prompts, err := services.ProfilePrompts([]uuid.UUID{userID})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch prompts"})
+	}
+	answers := prompts[userID]
+	if answers == nil {
+		answers = []models.ProfilePrompt{}
+	}
+	return c.JSON(fiber.Map{"answers": answers})
+}
+
+// UpdateMyPrompts replaces the current user's prompt answers (at most three, in order)
+// PUT /users/me/prompts {"answers": [{"prompt_id": "...", "answer": "..."}]}
+func UpdateMyPrompts(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	var req struct {
+		Answers []services.ProfilePromptAnswer `json:"answers"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	answers, err := services.SetProfilePrompts(userID, req.Answers)
+	if err != nil {
+		var validationErr *services.MessageValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": validationErr.Reason})
+		case errors.Is(err, services.ErrPromptNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Prompt not found"})
+		case errors.Is(err, services.ErrPromptAnswerScreened):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": "Keep contact details, links and payment info off your profile"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update prompts"})
+	}
+	return c.JSON(fiber.Map{"answers": answers})
+}
+
+// promptRequest is the body of the admin prompt endpoints
+type promptRequest struct {
+	Category     *string `json:"category"`
+	TextEN       *string `json:"text_en"`
+	TextAM       *string `json:"text_am"`
+	IsActive     *bool   `json:"is_active"`
+	DisplayOrder *int    `json:"display_order"`
+}
+
+// GetAdminPrompts lists the whole catalogue, retired prompts included
+// GET /admin/prompts
+func GetAdminPrompts(c *fiber.Ctx) error {
+	var prompts []models.Prompt
+	if err := database.DB.Order("is_active DESC, display_order ASC, created_at ASC").Find(&prompts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch prompts"})
+	}
+	return c.JSON(fiber.Map{"prompts": prompts})
+}
+
+// CreatePrompt adds a prompt to the catalogue; both texts are required
+// POST /admin/prompts {"text_en": "...", "text_am": "...", "category": "dating", "display_order": 9}
+func CreatePrompt(c *fiber.Ctx) error {
+	var req promptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+	if req.TextEN == nil || req.TextAM == nil || strings.TrimSpace(*req.TextEN) == "" || strings.TrimSpace(*req.TextAM) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "text_en and text_am are required"})
+	}
+
+	prompt := models.Prompt{
+		TextEN:   strings.TrimSpace(*req.TextEN),
+		TextAM:   strings.TrimSpace(*req.TextAM),
+		IsActive: true,
+	}
+	if req.Category != nil {
+		prompt.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.IsActive != nil {
+		prompt.IsActive = *req.IsActive
+	}
+	if req.DisplayOrder != nil {
+		prompt.DisplayOrder = *req.DisplayOrder
+	}
+
+	if err := database.DB.Select("*").Create(&prompt).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create prompt"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{"prompt": prompt})
+}
+
+// UpdatePrompt edits a prompt; omitted fields are left as they are
+// PUT /admin/prompts/:id
+func UpdatePrompt(c *fiber.Ctx) error {
+	promptID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var req promptRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+	}
+
+	var prompt models.Prompt
+	if err := database.DB.First(&prompt, "id = ?", promptID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Prompt not found"})
+	}
+
+	updates := map[string]interface{}{}
+	if req.TextEN != nil {
+		if strings.TrimSpace(*req.TextEN) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "text_en cannot be empty"})
+		}
+		updates["text_en"] = strings.TrimSpace(*req.TextEN)
+	}
+	if req.TextAM != nil {
+		if strings.TrimSpace(*req.TextAM) == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "text_am cannot be empty"})
+		}
+		updates["text_am"] = strings.TrimSpace(*req.TextAM)
+	}
+	if req.Category != nil {
+		updates["category"] = strings.TrimSpace(*req.Category)
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.DisplayOrder != nil {
+		updates["display_order"] = *req.DisplayOrder
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(&prompt).Updates(updates).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update prompt"})
+		}
+		database.DB.First(&prompt, "id = ?", promptID)
+	}
+	return c.JSON(fiber.Map{"prompt": prompt})
+}
+
+// DeletePrompt retires a prompt: it leaves the catalogue and answers to it stop showing.
+// The row is kept so chat messages quoting it still make sense.
+// DELETE /admin/prompts/:id
+func DeletePrompt(c *fiber.Ctx) error {
+	promptID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	result := database.DB.Model(&models.Prompt{}).Where("id = ?", promptID).Update("is_active", false)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete prompt"})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Prompt not found"})
+	}
+	return c.JSON(fiber.Map{"message": "Prompt retired"})
+}
@@ -2,12 +2,15 @@ package handlers
 
 import (
 	"fmt"
+	"log"
 	"lomi-backend/internal/database"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // ReportUser reports a user for inappropriate behavior
@@ -22,6 +25,9 @@ func ReportUser(c *fiber.Ctx) error {
 		Reason         string   `json:"reason"`
 		Description    string   `json:"description,omitempty"`
 		ScreenshotURLs []string `json:"screenshot_urls,omitempty"`
+		// Attach a snapshot of the conversation with the reported user as evidence
+		MatchID          string `json:"match_id,omitempty"`
+		AttachTranscript bool   `json:"attach_transcript,omitempty"`
 	}
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
@@ -32,6 +38,13 @@ func ReportUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
 	}
 
+	var matchID uuid.UUID
+	if req.AttachTranscript {
+		if matchID, err = uuid.Parse(req.MatchID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid match ID"})
+		}
+	}
+
 	if reporterID == reportedUserID {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Cannot report yourself"})
 	}
@@ -49,9 +62,20 @@ func ReportUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create report"})
 	}
 
+	// The report stands without the transcript; say so rather than failing it
+	transcriptAttached := false
+	if req.AttachTranscript {
+		if err := services.AttachReportTranscript(&report, matchID); err != nil {
+			log.Printf("⚠️ Failed to attach transcript to report %s: %v", report.ID, err)
+		} else {
+			transcriptAttached = true
+		}
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message": "Report submitted successfully",
-		"report":  report,
+		"message":             "Report submitted successfully",
+		"report":              report,
+		"transcript_attached": transcriptAttached,
 	})
 }
 
@@ -141,9 +165,29 @@ func BlockUser(c *fiber.Ctx) error {
 		BlockedID: blockedID,
 	}
 
-	if err := database.DB.Create(&block).Error; err != nil {
+	var endedMatchID *uuid.UUID
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&block).Error; err != nil {
+			return err
+		}
+
+		// End any active match between the two
+		var match models.Match
+		user1ID, user2ID := blockerID, blockedID
+		if user1ID.String() > user2ID.String() {
+			user1ID, user2ID = user2ID, user1ID
+		}
+		if err := tx.Where("user1_id = ? AND user2_id = ? AND is_active = ?", user1ID, user2ID, true).First(&match).Error; err == nil {
+			endedMatchID = &match.ID
+			return services.EndMatch(tx, &match, models.MatchStatusBlocked, &blockerID)
+		}
+		return nil
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to block user"})
 	}
+	if endedMatchID != nil {
+		services.InvalidateMatchMembers(*endedMatchID)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "User blocked successfully",
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	rewindCost   = 49               // Coins per rewind
+	rewindWindow = 10 * time.Minute // Only swipes this recent can be rewound
+)
+
+// errNothingToRewind is returned when the swipe was already rewound by a concurrent request
+var errNothingToRewind = errors.New("nothing to rewind")
+
+// RewindSwipe undoes the caller's most recent swipe (paid action) and puts the swiped user
+// back at the top of the caller's deck. If the swipe created a match, the match ends as
+// rewound: the other user's like stands and no rematch cooldown applies.
+func RewindSwipe(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	// Find the most recent swipe
+	var swipe models.Swipe
+	if err := database.DB.Where("swiper_id = ?", userID).
+		Order("created_at DESC").
+		First(&swipe).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Nothing to rewind"})
+	}
+
+	if time.Since(swipe.CreatedAt) > rewindWindow {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":          "Last swipe is too old to rewind",
+			"window_minutes": int(rewindWindow.Minutes()),
+		})
+	}
+
+	var (
+		currentUser models.User
+		endedMatch  *models.Match
+	)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Locked so concurrent rewinds cannot overdraw coins
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&currentUser, "id = ?", userID).Error; err != nil {
+			return err
+		}
+		if currentUser.CoinBalance < rewindCost {
+			return errInsufficientCoins
+		}
+
+		// A concurrent rewind of the same swipe deletes nothing and must not charge again
+		result := tx.Delete(&swipe)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNothingToRewind
+		}
+
+		// Undo the match if this like completed one
+		if swipe.Action == models.SwipeActionLike || swipe.Action == models.SwipeActionSuperLike {
+			user1ID, user2ID := userID, swipe.SwipedID
+			if user1ID.String() > user2ID.String() {
+				user1ID, user2ID = user2ID, user1ID
+			}
+
+			var match models.Match
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("user1_id = ? AND user2_id = ? AND status = ? AND epoch_started_at >= ?", user1ID, user2ID, models.MatchStatusActive, swipe.CreatedAt).
+				First(&match).Error; err == nil {
+				if err := services.RewindMatch(tx, &match, userID); err != nil {
+					return err
+				}
+				endedMatch = &match
+			}
+		}
+
+		// Deduct coins
+		currentUser.CoinBalance -= rewindCost
+		if err := tx.Model(&currentUser).Update("coin_balance", currentUser.CoinBalance).Error; err != nil {
+			return err
+		}
+
+		transaction := models.CoinTransaction{
+			UserID:          userID,
+			TransactionType: models.TransactionTypeRewind,
+			CoinAmount:      -rewindCost,
+			BalanceAfter:    currentUser.CoinBalance,
+			Metadata: models.JSONMap{
+				"swiped_id":     swipe.SwipedID.String(),
+				"action":        string(swipe.Action),
+				"match_removed": endedMatch != nil,
+			},
+		}
+		return tx.Create(&transaction).Error
+	})
+
+	switch {
+	case errors.Is(err, errInsufficientCoins):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":    "Insufficient coins",
+			"required": rewindCost,
+			"balance":  currentUser.CoinBalance,
+		})
+	case errors.Is(err, errNothingToRewind):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "Swipe was already rewound"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+	case err != nil:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to rewind swipe"})
+	}
+
+	if endedMatch != nil {
+		services.InvalidateMatchMembers(endedMatch.ID)
+	}
+
+	// Put the card back at the top of the deck
+	if database.RedisClient != nil {
+		if err := services.PushSwipeDeckCard(userID, swipe.SwipedID); err != nil {
+			log.Printf("⚠️ Failed to push rewound card to swipe deck: %v", err)
+		}
+	}
+
+	var swipedUser models.User
+	database.DB.First(&swipedUser, "id = ?", swipe.SwipedID)
+
+	return c.JSON(fiber.Map{
+		"message":        "Swipe rewound",
+		"user":           swipedUser,
+		"action":         swipe.Action,
+		"match_removed":  endedMatch != nil,
+		"coins_deducted": rewindCost,
+		"new_balance":    currentUser.CoinBalance,
+	})
+}
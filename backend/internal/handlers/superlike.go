@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"lomi-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	freeSuperLikesPerDay = 1
+	superLikeCost        = 29 // Coins per super like beyond the free quota
+)
+
+var errInsufficientCoins = errors.New("insufficient coins")
+
+// addisToday returns today's date in Addis Ababa (UTC+3) as midnight UTC,
+// the same convention as LastRevealDate
+func addisToday() time.Time {
+	addisTime := time.Now().UTC().Add(3 * time.Hour)
+	return time.Date(addisTime.Year(), addisTime.Month(), addisTime.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// superLikesLeft returns how many free super likes the user has left today
+func superLikesLeft(u models.User) int {
+	if u.LastSuperLikeDate.IsZero() || u.LastSuperLikeDate.Before(addisToday()) {
+		return freeSuperLikesPerDay
+	}
+	if left := freeSuperLikesPerDay - u.DailySuperLikesUsed; left > 0 {
+		return left
+	}
+	return 0
+}
+
+// chargeSuperLike uses a free super like if one is left today, otherwise deducts coins.
+// It runs inside tx and returns the coins charged (0 for a free one).
+func chargeSuperLike(tx *gorm.DB, userID, swipedID uuid.UUID) (int, *models.User, error) {
+	// Locked so concurrent super likes cannot both use the last free one or overdraw coins
+	var u models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, "id = ?", userID).Error; err != nil {
+		return 0, nil, err
+	}
+
+	today := addisToday()
+
+	if superLikesLeft(u) > 0 {
+		// Reset the counter on a new day
+		if u.LastSuperLikeDate.IsZero() || u.LastSuperLikeDate.Before(today) {
+			u.DailySuperLikesUsed = 0
+		}
+		u.DailySuperLikesUsed++
+		u.LastSuperLikeDate = today
+		err := tx.Model(&u).Updates(map[string]interface{}{
+			"daily_super_likes_used": u.DailySuperLikesUsed,
+			"last_super_like_date":   u.LastSuperLikeDate,
+		}).Error
+		return 0, &u, err
+	}
+
+	if u.CoinBalance < superLikeCost {
+		return 0, &u, errInsufficientCoins
+	}
+
+	u.CoinBalance -= superLikeCost
+	if err := tx.Model(&u).Update("coin_balance", u.CoinBalance).Error; err != nil {
+		return 0, &u, err
+	}
+
+	transaction := models.CoinTransaction{
+		UserID:          userID,
+		TransactionType: models.TransactionTypeSuperLike,
+		CoinAmount:      -superLikeCost,
+		BalanceAfter:    u.CoinBalance,
+		Metadata: models.JSONMap{
+			"swiped_id": swipedID.String(),
+		},
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return 0, &u, err
+	}
+
+	return superLikeCost, &u, nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	syncDefaultLimit = 200
+	syncMaxLimit     = 500
+)
+
+// ConversationSync holds the messages a client is missing in one conversation: new ones,
+// and older ones that changed (edited, deleted for everyone, reacted to, released).
+// The client's new cursor for the match is the UpdatedSeq of the last message; the
+// conversation is fully synced once that reaches LastSeq.
+type ConversationSync struct {
+	MatchID  uuid.UUID        `json:"match_id"`
+	Epoch    int              `json:"epoch"`
+	LastSeq  int64            `json:"last_seq"`
+	Messages []models.Message `json:"messages"`
+}
+
+// SyncResult is the answer to a sync request
+type SyncResult struct {
+	Conversations []ConversationSync `json:"conversations"`
+	Ended         []string           `json:"ended"`    // Cursors sent for matches that are no longer active
+	HasMore       bool               `json:"has_more"` // Limit reached: sync again with the new cursors
+}
+
+// syncMessages returns the messages created or changed after each cursor (match_id -> last
+// seq the client has) across all of the user's active matches, current epoch only.
+// Matches without a cursor are synced from the start of their epoch.
+func syncMessages(userID uuid.UUID, cursors map[string]int64, limit int) (*SyncResult, error) {
+	if limit <= 0 {
+		limit = syncDefaultLimit
+	}
+	if limit > syncMaxLimit {
+		limit = syncMaxLimit
+	}
+
+	var matches []models.Match
+	if err := database.DB.Select("id", "epoch", "last_seq").
+		Where("(user1_id = ? OR user2_id = ?) AND status = ?", userID, userID, models.MatchStatusActive).
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	result := &SyncResult{
+		Conversations: make([]ConversationSync, 0),
+		Ended:         make([]string, 0),
+	}
+
+	active := make(map[string]models.Match, len(matches))
+	for _, match := range matches {
+		active[match.ID.String()] = match
+	}
+
+	validCursors := make(map[string]int64, len(cursors))
+	for matchID, seq := range cursors {
+		if _, ok := active[matchID]; ok {
+			validCursors[matchID] = seq
+		} else {
+			result.Ended = append(result.Ended, matchID)
+		}
+	}
+	cursorsJSON, _ := json.Marshal(validCursors)
+
+	var messages []models.Message
+	if err := database.DB.Model(&models.Message{}).
+		Select("messages.*").
+		Joins("JOIN matches ON matches.id = messages.match_id AND matches.epoch = messages.epoch").
+		Joins("LEFT JOIN jsonb_each_text(?::jsonb) AS sync_cursor ON sync_cursor.key = messages.match_id::text", string(cursorsJSON)).
+		Where("(matches.user1_id = ? OR matches.user2_id = ?) AND matches.status = ?", userID, userID, models.MatchStatusActive).
+		Where("messages.updated_seq > COALESCE(sync_cursor.value::bigint, 0)").
+		Where("NOT EXISTS (SELECT 1 FROM message_deletions d WHERE d.message_id = messages.id AND d.user_id = ?)", userID).
+		Where("messages.held = ? OR messages.sender_id = ?", false, userID).
+		Preload("Reactions").
+		Order("messages.match_id, messages.updated_seq").
+		Limit(limit + 1).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+
+	if len(messages) > limit {
+		messages = messages[:limit]
+		result.HasMore = true
+	}
+
+	services.PresignChatMessages(messages)
+
+	for _, msg := range messages {
+		n := len(result.Conversations)
+		if n == 0 || result.Conversations[n-1].MatchID != msg.MatchID {
+			match := active[msg.MatchID.String()]
+			result.Conversations = append(result.Conversations, ConversationSync{
+				MatchID:  msg.MatchID,
+				Epoch:    match.Epoch,
+				LastSeq:  match.LastSeq,
+				Messages: make([]models.Message, 0),
+			})
+			n++
+		}
+		result.Conversations[n-1].Messages = append(result.Conversations[n-1].Messages, msg)
+	}
+
+	return result, nil
+}
+
+// SyncChats returns everything the client missed while offline
+// POST /chats/sync {"cursors": {"<match_id>": <last seq>}, "limit": 200}
+func SyncChats(c *fiber.Ctx) error {
+	user := c.Locals("user").(*jwt.Token)
+	claims := user.Claims.(jwt.MapClaims)
+	userIDStr := claims["user_id"].(string)
+	userID, _ := uuid.Parse(userIDStr)
+
+	var req struct {
+		Cursors map[string]int64 `json:"cursors"`
+		Limit   int              `json:"limit"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
+		}
+	}
+
+	result, err := syncMessages(userID, req.Cursors, req.Limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to sync messages"})
+	}
+
+	return c.JSON(result)
+}
@@ -18,6 +18,7 @@ type UpdateProfileRequest struct {
 	Interests        []string               `json:"interests"`
 	RelationshipGoal string                 `json:"relationship_goal"`
 	Preferences      map[string]interface{} `json:"preferences"`
+	ShowOnlineStatus *bool                  `json:"show_online_status"` // Privacy: online status and last seen
 }
 
 func GetMe(c *fiber.Ctx) error {
@@ -164,11 +165,21 @@ func UpdateProfile(c *fiber.Ctx) error {
 		}
 	}
 
+	presenceChanged := false
+	if req.ShowOnlineStatus != nil && *req.ShowOnlineStatus != dbUser.ShowOnlineStatus {
+		dbUser.ShowOnlineStatus = *req.ShowOnlineStatus
+		presenceChanged = true
+	}
+
 	// Profile is considered complete if basic info is present (City check is done elsewhere)
 
 	if err := database.DB.Save(&dbUser).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update profile"})
 	}
 
+	if presenceChanged {
+		PresenceSettingChanged(dbUser.ID)
+	}
+
 	return c.JSON(dbUser)
 }
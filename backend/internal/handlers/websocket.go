@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"lomi-backend/internal/database"
+	"lomi-backend/internal/middleware"
 	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,7 +20,10 @@ import (
 
 // WebSocket message types
 type WSMessage struct {
-	Type           string      `json:"type"` // "message", "typing", "read_receipt", "online_status", "delivery_status"
+	// "message", "typing", "read_receipt", "online_status", "delivery_status", "auth_refresh", "sync",
+	// "edit", "delete", "react", "presence_subscribe", "presence_unsubscribe"; server events: "message_edited", "message_deleted", "reaction",
+	// "buna_update", "buna_feedback", "error"
+	Type           string      `json:"type"`
 	MatchID        string      `json:"match_id,omitempty"`
 	MessageID      string      `json:"message_id,omitempty"`
 	Content        interface{} `json:"content,omitempty"`
@@ -27,168 +35,259 @@ type WSMessage struct {
 	IsTyping       bool        `json:"is_typing,omitempty"`
 	DeliveryStatus string      `json:"delivery_status,omitempty"` // "sent", "delivered", "read"
 	Timestamp      string      `json:"timestamp"`
+	Token          string      `json:"token,omitempty"` // auth_refresh: "Bearer <jwt>", "tma <initData>" or a bare JWT
+
+	// Offline sync: Seq numbers messages within a match and UpdatedSeq marks their latest
+	// change; a sync frame carries the client's cursors (match_id -> last seq it has).
+	// ClientMessageID makes resends idempotent.
+	Seq             int64            `json:"seq,omitempty"`
+	UpdatedSeq      int64            `json:"updated_seq,omitempty"`
+	ClientMessageID string           `json:"client_message_id,omitempty"`
+	Cursors         map[string]int64 `json:"cursors,omitempty"`
+	MediaID         string           `json:"media_id,omitempty"` // Photo, video and voice messages: the uploaded file
+
+	Metadata map[string]interface{} `json:"metadata,omitempty"` // Message metadata (buna invite state, media details)
+	Emoji    string                 `json:"emoji,omitempty"`    // react: empty removes the reaction
+	Scope    string                 `json:"scope,omitempty"`    // delete: "me" or "everyone"
+
+	// online_status: presence of UserID. PresenceHidden means the user turned off their
+	// online status; IsOnline and LastSeenAt are then left out.
+	UserID         string      `json:"user_id,omitempty"`
+	IsOnline       *bool       `json:"is_online,omitempty"`
+	LastSeenAt     string      `json:"last_seen_at,omitempty"`
+	PresenceHidden bool        `json:"presence_hidden,omitempty"`
+	Data           interface{} `json:"data,omitempty"` // Payload of message events such as buna_update
 }
 
-// Client represents a WebSocket connection
+// Client represents a WebSocket connection (one per device)
 type Client struct {
-	ID     uuid.UUID
-	UserID uuid.UUID
-	Conn   *websocket.Conn
-	Send   chan []byte
-	Hub    *Hub
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	DeviceID string // Client-supplied ?device_id=, defaults to the connection ID
+	Conn     *websocket.Conn
+	Send     chan []byte
+	Hub      *Hub
+
+	lastTouch time.Time          // Last presence refresh
+	refreshed chan time.Time     // New token expiry after an auth_refresh
+	done      chan struct{}      // Closed when the read loop ends
+	closeOnce sync.Once          // Only the first reason to close the session is sent
+	slow      bool               // Send buffer overflowed; owned by the hub's Run loop
+	watching  map[uuid.UUID]bool // Users whose presence this socket subscribed to; read loop only
 }
 
-// Hub manages WebSocket connections
-type Hub struct {
-	clients    map[uuid.UUID]*Client // user_id -> client
-	broadcast  chan []byte
-	register   chan *Client
-	unregister chan *Client
-}
+// presenceTouchInterval throttles presence refreshes from incoming frames and pongs
+const presenceTouchInterval = 30 * time.Second
+
+const (
+	wsWriteWait      = 10 * time.Second    // Time allowed to write a frame
+	wsPongWait       = 60 * time.Second    // Time allowed to read the next pong (or any frame)
+	wsPingPeriod     = wsPongWait * 9 / 10 // Pings go out before the read deadline passes
+	wsMaxMessageSize = 64 << 10            // Largest frame accepted from a client
+)
+
+// wsUserCheckInterval is how often an open socket re-checks that its user is still active
+const wsUserCheckInterval = time.Minute
+
+// Close codes sent when the server ends a session (4000-4999 are application-defined)
+const (
+	wsCloseTokenExpired = 4001
+	wsCloseUserInactive = 4003
+	wsCloseSlowConsumer = 4008
+)
+
+// HandleWebSocket handles WebSocket connections. The upgrade was authenticated by
+// middleware.WebSocketAuth, which left the token in Locals.
+func HandleWebSocket(c *websocket.Conn) {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		c.WriteJSON(fiber.Map{"error": "Token required"})
+		c.Close()
+		return
+	}
+
+	userID, err := middleware.TokenUserID(token)
+	if err != nil {
+		c.WriteJSON(fiber.Map{"error": "Invalid user ID"})
+		c.Close()
+		return
+	}
 
-func NewHub() *Hub {
-	return &Hub{
-		clients:    make(map[uuid.UUID]*Client),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+	client := &Client{
+		ID:        uuid.New(),
+		UserID:    userID,
+		DeviceID:  c.Query("device_id"),
+		Conn:      c,
+		Send:      make(chan []byte, 256),
+		Hub:       hub,
+		lastTouch: time.Now(),
+		refreshed: make(chan time.Time, 1),
+		done:      make(chan struct{}),
+		watching:  make(map[uuid.UUID]bool),
+	}
+	if client.DeviceID == "" || len(client.DeviceID) > 64 {
+		client.DeviceID = client.ID.String()
 	}
+
+	client.Hub.register <- client
+
+	// The connection is released when this handler returns, so the read loop runs here
+	go client.watchSession(middleware.TokenExpiry(token))
+	go client.writePump()
+	client.readPump()
 }
 
-func (h *Hub) Run() {
+// watchSession closes the socket when its token expires (unless refreshed in time) or
+// when the user is deactivated or deleted
+func (c *Client) watchSession(expiresAt time.Time) {
+	var expired <-chan time.Time
+	timer := time.NewTimer(time.Until(expiresAt))
+	defer timer.Stop()
+	if !expiresAt.IsZero() {
+		expired = timer.C
+	}
+
+	ticker := time.NewTicker(wsUserCheckInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case client := <-h.register:
-			h.clients[client.UserID] = client
-			// Update user online status
-			database.DB.Model(&models.User{}).Where("id = ?", client.UserID).Updates(map[string]interface{}{
-				"is_online":    true,
-				"last_seen_at": time.Now(),
-			})
-
-		case client := <-h.unregister:
-			if _, ok := h.clients[client.UserID]; ok {
-				delete(h.clients, client.UserID)
-				close(client.Send)
-				// Update user offline status
-				database.DB.Model(&models.User{}).Where("id = ?", client.UserID).Updates(map[string]interface{}{
-					"is_online":    false,
-					"last_seen_at": time.Now(),
-				})
+		case <-c.done:
+			return
+
+		case expiresAt = <-c.refreshed:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(expiresAt))
+			expired = nil
+			if !expiresAt.IsZero() {
+				expired = timer.C
 			}
 
-		case message := <-h.broadcast:
-			// Broadcast to all clients (or specific clients based on message)
-			var wsMsg WSMessage
-			if err := json.Unmarshal(message, &wsMsg); err == nil {
-				// Handle different message types
-				if wsMsg.Type == "message" || wsMsg.Type == "delivery_status" || wsMsg.Type == "read_receipt" {
-					// Send to specific match participants
-					var match models.Match
-					if err := database.DB.First(&match, "id = ?", wsMsg.MatchID).Error; err == nil {
-						// Send to user1
-						if client, ok := h.clients[match.User1ID]; ok {
-							select {
-							case client.Send <- message:
-							default:
-								close(client.Send)
-								delete(h.clients, client.UserID)
-							}
-						}
-						// Send to user2
-						if client, ok := h.clients[match.User2ID]; ok {
-							select {
-							case client.Send <- message:
-							default:
-								close(client.Send)
-								delete(h.clients, client.UserID)
-							}
-						}
-					}
-				} else if wsMsg.Type == "typing" {
-					// Send typing indicator to the other user in the match
-					var match models.Match
-					if err := database.DB.First(&match, "id = ?", wsMsg.MatchID).Error; err == nil {
-						var targetUserID uuid.UUID
-						senderID, _ := uuid.Parse(wsMsg.SenderID)
-						if match.User1ID == senderID {
-							targetUserID = match.User2ID
-						} else {
-							targetUserID = match.User1ID
-						}
-						if client, ok := h.clients[targetUserID]; ok {
-							select {
-							case client.Send <- message:
-							default:
-								close(client.Send)
-								delete(h.clients, client.UserID)
-							}
-						}
-					}
-				}
+		case <-expired:
+			c.closeSession(wsCloseTokenExpired, "token expired")
+			return
+
+		case <-ticker.C:
+			if err := middleware.CheckUserActive(c.UserID); errors.Is(err, middleware.ErrUserInactive) {
+				c.closeSession(wsCloseUserInactive, "account is not active")
+				return
 			}
 		}
 	}
 }
 
-var hub *Hub
-
-func init() {
-	hub = NewHub()
-	go hub.Run()
+// closeSession sends a close frame and drops the connection; readPump then unregisters the
+// client. Safe to call from any goroutine, any number of times.
+func (c *Client) closeSession(code int, reason string) {
+	c.closeOnce.Do(func() {
+		c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+		c.Conn.Close()
+	})
 }
 
-// HandleWebSocket handles WebSocket connections
-func HandleWebSocket(c *websocket.Conn) {
-	// Extract user from query params (token should be in query string)
-	tokenString := c.Query("token")
-	if tokenString == "" {
-		c.WriteJSON(fiber.Map{"error": "Token required"})
-		c.Close()
-		return
+// ackMessage tells the sender their message is stored, with its ID and sequence number
+func (c *Client) ackMessage(matchID string, msg *models.Message) {
+	deliveryMsg := WSMessage{
+		Type:           "delivery_status",
+		MatchID:        matchID,
+		MessageID:      msg.ID.String(),
+		Seq:            msg.Seq,
+		DeliveryStatus: "delivered",
+		Timestamp:      time.Now().Format(time.RFC3339),
+	}
+	if msg.ClientMessageID != nil {
+		deliveryMsg.ClientMessageID = *msg.ClientMessageID
+	}
+	deliveryBytes, _ := json.Marshal(deliveryMsg)
+	select {
+	case c.Send <- deliveryBytes:
+	default:
 	}
+}
 
-	// Parse JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte("your-super-secret-jwt-key-change-in-production"), nil // TODO: Get from config
+// sendError reports a rejected frame to this socket
+func (c *Client) sendError(wsMsg WSMessage, reason string) {
+	errorBytes, _ := json.Marshal(WSMessage{
+		Type:            "error",
+		MatchID:         wsMsg.MatchID,
+		ClientMessageID: wsMsg.ClientMessageID,
+		Content:         reason,
+		Timestamp:       time.Now().Format(time.RFC3339),
 	})
+	select {
+	case c.Send <- errorBytes:
+	default:
+	}
+}
 
-	if err != nil || !token.Valid {
-		c.WriteJSON(fiber.Map{"error": "Invalid token"})
-		c.Close()
-		return
+// touch refreshes presence at most once per presenceTouchInterval (read loop only)
+func (c *Client) touch() {
+	if time.Since(c.lastTouch) > presenceTouchInterval {
+		c.Hub.touchPresence(c)
+		c.lastTouch = time.Now()
 	}
+}
 
-	claims := token.Claims.(jwt.MapClaims)
-	userIDStr := claims["user_id"].(string)
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.WriteJSON(fiber.Map{"error": "Invalid user ID"})
-		c.Close()
-		return
+// refreshAuth handles an auth_refresh frame: the new token must be valid and belong to the
+// same user; the socket then lives until the new expiry
+func (c *Client) refreshAuth(wsMsg WSMessage) {
+	reply := WSMessage{Type: "auth_refreshed", Timestamp: time.Now().Format(time.RFC3339)}
+
+	authHeader := wsMsg.Token
+	if !strings.Contains(authHeader, " ") {
+		authHeader = "Bearer " + authHeader // A bare JWT
 	}
 
-	client := &Client{
-		ID:     uuid.New(),
-		UserID: userID,
-		Conn:   c,
-		Send:   make(chan []byte, 256),
-		Hub:    hub,
+	token, err := middleware.AuthenticateHeader(authHeader)
+	if err == nil {
+		var userID uuid.UUID
+		if userID, err = middleware.TokenUserID(token); err == nil && userID != c.UserID {
+			err = middleware.ErrInvalidToken
+		}
 	}
 
-	client.Hub.register <- client
+	if err != nil {
+		reply.Type = "auth_error"
+		reply.Content = "Invalid or expired token"
+	} else {
+		expiresAt := middleware.TokenExpiry(token)
+		select {
+		case <-c.refreshed: // Replace an expiry the watcher has not picked up yet
+		default:
+		}
+		c.refreshed <- expiresAt
+		if !expiresAt.IsZero() {
+			reply.Content = expiresAt.Format(time.RFC3339)
+		}
+	}
 
-	// Start goroutines
-	go client.writePump()
-	go client.readPump()
+	replyBytes, _ := json.Marshal(reply)
+	select {
+	case c.Send <- replyBytes:
+	default:
+	}
 }
 
 func (c *Client) readPump() {
 	defer func() {
+		close(c.done)
+		c.Hub.unwatchAll(c)
 		c.Hub.unregister <- c
 		c.Conn.Close()
 	}()
 
+	c.Conn.SetReadLimit(wsMaxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		c.touch()
+		return c.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
 	for {
 		_, message, err := c.Conn.ReadMessage()
 		if err != nil {
@@ -198,6 +297,9 @@ func (c *Client) readPump() {
 			break
 		}
 
+		c.Conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		c.touch()
+
 		var wsMsg WSMessage
 		if err := json.Unmarshal(message, &wsMsg); err != nil {
 			continue
@@ -205,72 +307,131 @@ func (c *Client) readPump() {
 
 		// Handle incoming messages
 		switch wsMsg.Type {
+		case "auth_refresh":
+			c.refreshAuth(wsMsg)
+
 		case "message":
-			// Save message to database and broadcast
-			matchID, _ := uuid.Parse(wsMsg.MatchID)
-			msg := models.Message{
-				MatchID:     matchID,
-				SenderID:    c.UserID,
-				MessageType: models.MessageType(wsMsg.MessageType),
-				Content:     "",
-				MediaURL:    wsMsg.MediaURL,
-				IsRead:      false,
+			// Same pipeline as POST /chats/:id/messages
+			matchID, err := uuid.Parse(wsMsg.MatchID)
+			if err != nil {
+				c.sendError(wsMsg, "Invalid match ID")
+				continue
 			}
 
-			// Handle content based on message type
-			if wsMsg.Content != nil {
-				if contentStr, ok := wsMsg.Content.(string); ok {
-					msg.Content = contentStr
+			var mediaID uuid.UUID
+			if wsMsg.MediaID != "" {
+				if mediaID, err = uuid.Parse(wsMsg.MediaID); err != nil {
+					c.sendError(wsMsg, "Invalid media ID")
+					continue
 				}
 			}
 
-			// Handle gift messages
-			if wsMsg.GiftID != "" {
-				giftID, _ := uuid.Parse(wsMsg.GiftID)
-				msg.GiftID = &giftID
+			content, _ := wsMsg.Content.(string)
+			msg, _, err := services.SendChatMessage(c.UserID, services.SendMessageInput{
+				MatchID:         matchID,
+				MessageType:     models.MessageType(wsMsg.MessageType),
+				Content:         content,
+				MediaURL:        wsMsg.MediaURL,
+				MediaID:         mediaID,
+				Metadata:        wsMsg.Metadata, // prompt_reply: {"prompt_answer_id": ...}
+				ClientMessageID: wsMsg.ClientMessageID,
+			})
+			if err != nil {
+				_, reason := messageError(err)
+				c.sendError(wsMsg, reason)
+				continue
 			}
 
-			// Get receiver from match
-			var match models.Match
-			if err := database.DB.First(&match, "id = ?", matchID).Error; err == nil {
-				if match.User1ID == c.UserID {
-					msg.ReceiverID = match.User2ID
-				} else {
-					msg.ReceiverID = match.User1ID
-				}
-				if err := database.DB.Create(&msg).Error; err == nil {
-					// Update message ID in WS message
-					wsMsg.MessageID = msg.ID.String()
-					wsMsg.SenderID = c.UserID.String()
-					wsMsg.ReceiverID = msg.ReceiverID.String()
-					wsMsg.DeliveryStatus = "sent"
-					wsMsg.Timestamp = time.Now().Format(time.RFC3339)
-
-					// Broadcast to hub
-					broadcastMsg, _ := json.Marshal(wsMsg)
-					c.Hub.broadcast <- broadcastMsg
-
-					// Send delivery status to sender
-					deliveryMsg := WSMessage{
-						Type:           "delivery_status",
-						MatchID:        wsMsg.MatchID,
-						MessageID:      msg.ID.String(),
-						DeliveryStatus: "delivered",
-						Timestamp:      time.Now().Format(time.RFC3339),
-					}
-					deliveryBytes, _ := json.Marshal(deliveryMsg)
-					select {
-					case c.Send <- deliveryBytes:
-					default:
-					}
+			// Send delivery status to sender (also for a resend of a stored message)
+			c.ackMessage(wsMsg.MatchID, msg)
+
+		case "edit", "delete", "react":
+			// Same as PUT/DELETE /chats/:id/messages/:message_id; the result arrives as a
+			// message_edited, message_deleted or reaction event
+			matchID, err := uuid.Parse(wsMsg.MatchID)
+			if err != nil {
+				c.sendError(wsMsg, "Invalid match ID")
+				continue
+			}
+			messageID, err := uuid.Parse(wsMsg.MessageID)
+			if err != nil {
+				c.sendError(wsMsg, "Invalid message ID")
+				continue
+			}
+
+			switch wsMsg.Type {
+			case "edit":
+				content, _ := wsMsg.Content.(string)
+				_, err = services.EditMessage(c.UserID, matchID, messageID, content)
+			case "delete":
+				scope := services.MessageDeleteScope(wsMsg.Scope)
+				if scope == "" {
+					scope = services.MessageDeleteForMe
 				}
+				_, err = services.DeleteMessage(c.UserID, matchID, messageID, scope)
+			case "react":
+				_, err = services.ReactToMessage(c.UserID, matchID, messageID, wsMsg.Emoji)
+			}
+			if err != nil {
+				_, reason := messageError(err)
+				c.sendError(wsMsg, reason)
+			}
+
+		case "sync":
+			// Everything after the client's per-match cursors
+			result, err := syncMessages(c.UserID, wsMsg.Cursors, 0)
+			if err != nil {
+				continue
+			}
+			syncBytes, _ := json.Marshal(WSMessage{
+				Type:      "sync",
+				Content:   result,
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			select {
+			case c.Send <- syncBytes:
+			default:
+			}
+
+		case "presence_subscribe", "presence_unsubscribe":
+			// A chat screen follows its peer's online status
+			matchID, err := uuid.Parse(wsMsg.MatchID)
+			if err != nil {
+				c.sendError(wsMsg, "Invalid match ID")
+				continue
+			}
+			peerID, err := services.ActiveMatchPeer(matchID, c.UserID)
+			if err != nil {
+				c.sendError(wsMsg, "Match not found")
+				continue
+			}
+
+			if wsMsg.Type == "presence_unsubscribe" {
+				c.Hub.unwatchPresence(c, peerID)
+				continue
+			}
+			if !c.Hub.watchPresence(c, peerID) {
+				c.sendError(wsMsg, "Too many presence subscriptions")
+				continue
+			}
+
+			// Current state first; changes follow as online_status frames
+			frame, err := presenceFrame(peerID, c.Hub.IsConnected(peerID))
+			if err != nil {
+				continue
+			}
+			frame.MatchID = wsMsg.MatchID
+			frameBytes, _ := json.Marshal(frame)
+			select {
+			case c.Send <- frameBytes:
+			default:
 			}
 
 		case "typing":
 			// Add sender ID to typing message
 			wsMsg.SenderID = c.UserID.String()
 			typingMsg, _ := json.Marshal(wsMsg)
-			c.Hub.broadcast <- typingMsg
+			c.Hub.Broadcast(c.UserID, typingMsg)
 
 		case "read_receipt":
 			// Mark messages as read
@@ -278,7 +439,7 @@ func (c *Client) readPump() {
 			now := time.Now()
 			var readMessages []models.Message
 			database.DB.Model(&models.Message{}).
-				Where("match_id = ? AND receiver_id = ? AND is_read = ?", matchID, c.UserID, false).
+				Where("match_id = ? AND receiver_id = ? AND is_read = ? AND held = ?", matchID, c.UserID, false, false).
 				Find(&readMessages)
 
 			if len(readMessages) > 0 {
@@ -304,20 +465,28 @@ func (c *Client) readPump() {
 						Timestamp:      time.Now().Format(time.RFC3339),
 					}
 					readBytes, _ := json.Marshal(readReceipt)
-					c.Hub.broadcast <- readBytes
+					c.Hub.Broadcast(c.UserID, readBytes)
 				}
 			}
 		}
 	}
 }
 
+// writePump is the only writer of data frames. It also pings the client so dead
+// connections are noticed by the read deadline.
 func (c *Client) writePump() {
-	defer c.Conn.Close()
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
 
 	for {
 		select {
 		case message, ok := <-c.Send:
+			c.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
 			if !ok {
+				// The hub unregistered this client
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
 				return
 			}
@@ -326,6 +495,12 @@ func (c *Client) writePump() {
 				log.Printf("Write error: %v", err)
 				return
 			}
+
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
 }
@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/services"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// wsUserChannelPrefix is the Redis pub/sub channel prefix for frames addressed to a user.
+// Every API node subscribes to ws:user:{user_id} for the users connected to it, so a frame
+// published by any node reaches the receiver wherever their socket lives.
+const wsUserChannelPrefix = "ws:user:"
+
+func wsUserChannel(userID uuid.UUID) string {
+	return wsUserChannelPrefix + userID.String()
+}
+
+// userFrame is a frame to deliver to a user's sockets on this node
+type userFrame struct {
+	UserID  uuid.UUID
+	Payload []byte
+}
+
+// wsPresencePrefix keys a sorted set of a user's open sockets across all nodes
+// (member "{connection_id}:{device_id}", score = last activity, unix seconds)
+const wsPresencePrefix = "ws:presence:"
+
+// wsPresenceStaleAfter drops sockets from presence that stopped heartbeating (pongs and
+// frames refresh them, see touchPresence), e.g. after a node crashed
+const wsPresenceStaleAfter = 2 * wsPongWait
+
+func wsPresenceKey(userID uuid.UUID) string {
+	return wsPresencePrefix + userID.String()
+}
+
+func wsPresenceMember(client *Client) string {
+	return client.ID.String() + ":" + client.DeviceID
+}
+
+// Hub manages the WebSocket connections on this node. A user may have several sockets
+// (phone, Telegram desktop, ...); frames go to all of them.
+// The Run loop owns the clients map and is the only place a client's Send channel is
+// closed; everything else talks to it through channels.
+type Hub struct {
+	clients    map[uuid.UUID]map[*Client]bool // user_id -> open sockets on this node
+	register   chan *Client
+	unregister chan *Client
+	deliver    chan userFrame
+	pubsub     *redis.PubSub // nil without Redis: node-local delivery only
+
+	// Presence subscriptions without Redis (see ws_presence.go)
+	watchMu  sync.Mutex
+	watchers map[uuid.UUID]map[*Client]bool
+
+	shutdown chan struct{}
+	drained  chan struct{} // Closed once every socket has unregistered after shutdown
+	closing  bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		clients:    make(map[uuid.UUID]map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		deliver:    make(chan userFrame, 256),
+		watchers:   make(map[uuid.UUID]map[*Client]bool),
+		shutdown:   make(chan struct{}),
+		drained:    make(chan struct{}),
+	}
+}
+
+var hub *Hub
+
+// InitWebSocketHub starts the node's hub and, when Redis is available, its pub/sub listener
+func InitWebSocketHub() {
+	hub = NewHub()
+
+	if database.RedisClient != nil {
+		hub.pubsub = database.RedisClient.Subscribe(context.Background())
+		go hub.listen()
+		log.Printf("✅ WebSocket hub started (Redis pub/sub fan-out)")
+	} else {
+		log.Printf("⚠️ WebSocket hub started without Redis: delivery is limited to this node")
+	}
+
+	go hub.Run()
+
+	services.SetMessageDelivery(hub)
+}
+
+// ShutdownWebSocketHub closes every socket on this node and waits for them to drain
+func ShutdownWebSocketHub(ctx context.Context) error {
+	if hub == nil {
+		return nil
+	}
+	return hub.Shutdown(ctx)
+}
+
+func (h *Hub) Run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.addClient(client)
+			if h.closing {
+				go client.closeSession(websocket.CloseGoingAway, "server shutting down")
+			}
+
+		case client := <-h.unregister:
+			h.removeClient(client)
+			h.checkDrained()
+
+		case frame := <-h.deliver:
+			for client := range h.clients[frame.UserID] {
+				if client.slow {
+					continue
+				}
+				select {
+				case client.Send <- frame.Payload:
+				default:
+					// Slow consumer: disconnect this device only. Its read loop unregisters it,
+					// which closes Send; closing here would race with pending frames.
+					client.slow = true
+					go client.closeSession(wsCloseSlowConsumer, "slow consumer")
+				}
+			}
+
+		case <-h.shutdown:
+			if h.closing {
+				continue
+			}
+			h.closing = true
+			for _, devices := range h.clients {
+				for client := range devices {
+					go client.closeSession(websocket.CloseGoingAway, "server shutting down")
+				}
+			}
+			h.checkDrained()
+		}
+	}
+}
+
+// Shutdown sends every socket on this node a "going away" close frame and waits until all
+// of them have unregistered (so presence is cleaned up) or ctx is done
+func (h *Hub) Shutdown(ctx context.Context) error {
+	select {
+	case h.shutdown <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-h.drained:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if h.pubsub != nil {
+		h.pubsub.Close()
+	}
+	return nil
+}
+
+func (h *Hub) checkDrained() {
+	if !h.closing || len(h.clients) > 0 {
+		return
+	}
+	select {
+	case <-h.drained:
+	default:
+		close(h.drained)
+	}
+}
+
+func (h *Hub) addClient(client *Client) {
+	ctx := context.Background()
+
+	devices, ok := h.clients[client.UserID]
+	if !ok {
+		devices = make(map[*Client]bool)
+		h.clients[client.UserID] = devices
+
+		// First socket for this user on this node
+		if h.pubsub != nil {
+			if err := h.pubsub.Subscribe(ctx, wsUserChannel(client.UserID)); err != nil {
+				log.Printf("❌ Failed to subscribe to %s: %v", wsUserChannel(client.UserID), err)
+			}
+		}
+	}
+	devices[client] = true
+
+	// The user comes online with their first fresh socket on any node
+	cameOnline := len(devices) == 1
+	if database.RedisClient != nil {
+		key := wsPresenceKey(client.UserID)
+		pipe := database.RedisClient.TxPipeline()
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+wsPresenceStaleScore())
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().Unix()), Member: wsPresenceMember(client)})
+		pipe.Expire(ctx, key, wsPresenceStaleAfter)
+		sockets := pipe.ZCard(ctx, key)
+		if _, err := pipe.Exec(ctx); err == nil {
+			cameOnline = sockets.Val() == 1
+		}
+	}
+	if !cameOnline {
+		return
+	}
+
+	go func(userID uuid.UUID) {
+		database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"is_online":    true,
+			"last_seen_at": time.Now(),
+		})
+		h.publishPresence(userID, true)
+	}(client.UserID)
+}
+
+func (h *Hub) removeClient(client *Client) {
+	ctx := context.Background()
+
+	devices, ok := h.clients[client.UserID]
+	if !ok || !devices[client] {
+		return
+	}
+	delete(devices, client)
+	close(client.Send)
+
+	if len(devices) == 0 {
+		delete(h.clients, client.UserID)
+		if h.pubsub != nil {
+			h.pubsub.Unsubscribe(ctx, wsUserChannel(client.UserID))
+		}
+	}
+
+	// The user is offline only when their last device on any node disconnects
+	online := len(devices) > 0
+	if database.RedisClient != nil {
+		key := wsPresenceKey(client.UserID)
+		pipe := database.RedisClient.TxPipeline()
+		pipe.ZRem(ctx, key, wsPresenceMember(client))
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+wsPresenceStaleScore())
+		remaining := pipe.ZCard(ctx, key)
+		if _, err := pipe.Exec(ctx); err == nil {
+			online = remaining.Val() > 0
+		}
+	}
+
+	if online {
+		return
+	}
+
+	go func(userID uuid.UUID) {
+		database.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"is_online":    false,
+			"last_seen_at": time.Now(),
+		})
+		h.publishPresence(userID, false)
+	}(client.UserID)
+}
+
+// touchPresence is the presence heartbeat: it refreshes a socket's presence entry and its
+// subscriptions so they are not treated as stale (read loop only)
+func (h *Hub) touchPresence(client *Client) {
+	if database.RedisClient == nil {
+		return
+	}
+	h.touchPresenceWatches(client)
+
+	ctx := context.Background()
+	key := wsPresenceKey(client.UserID)
+	pipe := database.RedisClient.Pipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().Unix()), Member: wsPresenceMember(client)})
+	pipe.Expire(ctx, key, wsPresenceStaleAfter)
+	pipe.Exec(ctx)
+}
+
+// UserDevices returns the device IDs of a user's open sockets across all nodes
+func UserDevices(userID uuid.UUID) ([]string, error) {
+	if database.RedisClient == nil {
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	members, err := database.RedisClient.ZRangeByScore(ctx, wsPresenceKey(userID), &redis.ZRangeBy{Min: wsPresenceStaleScore(), Max: "+inf"}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	devices := make([]string, 0, len(members))
+	for _, m := range members {
+		if idx := strings.Index(m, ":"); idx >= 0 {
+			devices = append(devices, m[idx+1:])
+		}
+	}
+	return devices, nil
+}
+
+// listen forwards frames published for users on this node into the Run loop
+func (h *Hub) listen() {
+	for msg := range h.pubsub.Channel() {
+		userID, err := uuid.Parse(strings.TrimPrefix(msg.Channel, wsUserChannelPrefix))
+		if err != nil {
+			continue
+		}
+		h.deliver <- userFrame{UserID: userID, Payload: []byte(msg.Payload)}
+	}
+}
+
+// SendToUser delivers a frame to a user's sockets on whichever node they are connected to
+func (h *Hub) SendToUser(userID uuid.UUID, payload []byte) {
+	if h.pubsub != nil {
+		err := database.RedisClient.Publish(context.Background(), wsUserChannel(userID), payload).Err()
+		if err == nil {
+			return
+		}
+		log.Printf("⚠️ Failed to publish WebSocket frame, delivering locally: %v", err)
+	}
+	h.deliver <- userFrame{UserID: userID, Payload: payload}
+}
+
+// DeliverMessage sends a stored chat message to both participants' sockets, or only the
+// sender's while it is held for review (services.MessageDelivery)
+func (h *Hub) DeliverMessage(msg models.Message) {
+	frame := WSMessage{
+		Type:           "message",
+		MatchID:        msg.MatchID.String(),
+		MessageID:      msg.ID.String(),
+		Content:        msg.Content,
+		MessageType:    string(msg.MessageType),
+		MediaURL:       msg.MediaURL,
+		SenderID:       msg.SenderID.String(),
+		ReceiverID:     msg.ReceiverID.String(),
+		Seq:            msg.Seq,
+		UpdatedSeq:     msg.UpdatedSeq,
+		DeliveryStatus: "sent",
+		Timestamp:      msg.CreatedAt.Format(time.RFC3339),
+		Metadata:       msg.Metadata,
+	}
+	if msg.GiftID != nil {
+		frame.GiftID = msg.GiftID.String()
+	}
+	if msg.ClientMessageID != nil {
+		frame.ClientMessageID = *msg.ClientMessageID
+	}
+
+	payload, _ := json.Marshal(frame)
+	h.SendToUser(msg.SenderID, payload)
+	if !msg.Held {
+		h.SendToUser(msg.ReceiverID, payload)
+	}
+}
+
+// DeliverMessageEvent sends an event about a stored message, such as a buna invite state
+// change, to both participants' sockets (services.MessageDelivery)
+func (h *Hub) DeliverMessageEvent(eventType string, msg models.Message, data interface{}) {
+	payload := messageEventFrame(eventType, msg, data)
+	h.SendToUser(msg.SenderID, payload)
+	if !msg.Held {
+		h.SendToUser(msg.ReceiverID, payload)
+	}
+}
+
+// DeliverUserMessageEvent sends an event about a stored message to one participant's
+// sockets (services.MessageDelivery)
+func (h *Hub) DeliverUserMessageEvent(userID uuid.UUID, eventType string, msg models.Message, data interface{}) {
+	h.SendToUser(userID, messageEventFrame(eventType, msg, data))
+}
+
+func messageEventFrame(eventType string, msg models.Message, data interface{}) []byte {
+	frame := WSMessage{
+		Type:        eventType,
+		MatchID:     msg.MatchID.String(),
+		MessageID:   msg.ID.String(),
+		MessageType: string(msg.MessageType),
+		SenderID:    msg.SenderID.String(),
+		ReceiverID:  msg.ReceiverID.String(),
+		Seq:         msg.Seq,
+		UpdatedSeq:  msg.UpdatedSeq,
+		Data:        data,
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+
+	payload, _ := json.Marshal(frame)
+	return payload
+}
+
+// IsConnected reports whether the user has a socket open on any node
+// (services.MessageDelivery)
+func (h *Hub) IsConnected(userID uuid.UUID) bool {
+	if database.RedisClient == nil {
+		var user models.User
+		if err := database.DB.Select("id", "is_online").First(&user, "id = ?", userID).Error; err != nil {
+			return false
+		}
+		return user.IsOnline
+	}
+
+	devices, err := UserDevices(userID)
+	return err == nil && len(devices) > 0
+}
+
+// Broadcast routes a frame from a match participant to the participants it is meant for.
+// Membership comes from the match cache, so typing frames cost no database round-trip.
+func (h *Hub) Broadcast(senderID uuid.UUID, message []byte) {
+	var wsMsg WSMessage
+	if err := json.Unmarshal(message, &wsMsg); err != nil {
+		return
+	}
+
+	matchID, err := uuid.Parse(wsMsg.MatchID)
+	if err != nil {
+		return
+	}
+	peerID, err := services.ActiveMatchPeer(matchID, senderID)
+	if err != nil {
+		return
+	}
+
+	switch wsMsg.Type {
+	case "message", "delivery_status", "read_receipt":
+		// Send to both match participants
+		h.SendToUser(senderID, message)
+		h.SendToUser(peerID, message)
+
+	case "typing":
+		// Send typing indicator to the other user in the match
+		h.SendToUser(peerID, message)
+	}
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Presence lives in Redis: ws:presence:{user_id} holds the user's sockets with a heartbeat
+// score (see touchPresence), so a user is online while any socket beat recently. The users
+// table only records transitions (is_online, last_seen_at) for ranking and for "last seen"
+// once the sockets are gone.
+//
+// A chat screen subscribes to its peer with a presence_subscribe frame; watchers of a user
+// are kept in ws:presence:watchers:{user_id} (member "{watcher_id}:{connection_id}") and get
+// an online_status frame whenever the user comes online or goes offline. Users who turned
+// off ShowOnlineStatus are reported as presence_hidden.
+const wsPresenceWatchersPrefix = "ws:presence:watchers:"
+
+// wsMaxPresenceWatches bounds how many users one socket may watch
+const wsMaxPresenceWatches = 20
+
+func wsPresenceWatchersKey(userID uuid.UUID) string {
+	return wsPresenceWatchersPrefix + userID.String()
+}
+
+func wsPresenceWatcherMember(client *Client) string {
+	return client.UserID.String() + ":" + client.ID.String()
+}
+
+func wsPresenceStaleScore() string {
+	return strconv.FormatInt(time.Now().Add(-wsPresenceStaleAfter).Unix(), 10)
+}
+
+// watchPresence subscribes a socket to a user's online/offline changes (read loop only)
+func (h *Hub) watchPresence(client *Client, userID uuid.UUID) bool {
+	if !client.watching[userID] && len(client.watching) >= wsMaxPresenceWatches {
+		return false
+	}
+	client.watching[userID] = true
+
+	if database.RedisClient != nil {
+		ctx := context.Background()
+		key := wsPresenceWatchersKey(userID)
+		pipe := database.RedisClient.Pipeline()
+		pipe.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().Unix()), Member: wsPresenceWatcherMember(client)})
+		pipe.Expire(ctx, key, wsPresenceStaleAfter)
+		pipe.Exec(ctx)
+		return true
+	}
+
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+	watchers, ok := h.watchers[userID]
+	if !ok {
+		watchers = make(map[*Client]bool)
+		h.watchers[userID] = watchers
+	}
+	watchers[client] = true
+	return true
+}
+
+// unwatchPresence ends a subscription (read loop only)
+func (h *Hub) unwatchPresence(client *Client, userID uuid.UUID) {
+	if !client.watching[userID] {
+		return
+	}
+	delete(client.watching, userID)
+
+	if database.RedisClient != nil {
+		database.RedisClient.ZRem(context.Background(), wsPresenceWatchersKey(userID), wsPresenceWatcherMember(client))
+		return
+	}
+
+	h.watchMu.Lock()
+	defer h.watchMu.Unlock()
+	if watchers, ok := h.watchers[userID]; ok {
+		delete(watchers, client)
+		if len(watchers) == 0 {
+			delete(h.watchers, userID)
+		}
+	}
+}
+
+// unwatchAll ends every subscription of a closing socket
+func (h *Hub) unwatchAll(client *Client) {
+	for userID := range client.watching {
+		h.unwatchPresence(client, userID)
+	}
+}
+
+// touchPresenceWatches keeps a socket's subscriptions from going stale (read loop only)
+func (h *Hub) touchPresenceWatches(client *Client) {
+	if database.RedisClient == nil || len(client.watching) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	now := float64(time.Now().Unix())
+	pipe := database.RedisClient.Pipeline()
+	for userID := range client.watching {
+		key := wsPresenceWatchersKey(userID)
+		pipe.ZAdd(ctx, key, redis.Z{Score: now, Member: wsPresenceWatcherMember(client)})
+		pipe.Expire(ctx, key, wsPresenceStaleAfter)
+	}
+	pipe.Exec(ctx)
+}
+
+// presenceWatchers returns the users watching userID
+func (h *Hub) presenceWatchers(userID uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+
+	if database.RedisClient != nil {
+		members, err := database.RedisClient.ZRangeByScore(context.Background(), wsPresenceWatchersKey(userID),
+			&redis.ZRangeBy{Min: wsPresenceStaleScore(), Max: "+inf"}).Result()
+		if err != nil {
+			return nil
+		}
+		for _, m := range members {
+			watcherID, _, _ := strings.Cut(m, ":")
+			if id, err := uuid.Parse(watcherID); err == nil {
+				seen[id] = true
+			}
+		}
+	} else {
+		h.watchMu.Lock()
+		for client := range h.watchers[userID] {
+			seen[client.UserID] = true
+		}
+		h.watchMu.Unlock()
+	}
+
+	watchers := make([]uuid.UUID, 0, len(seen))
+	for id := range seen {
+		watchers = append(watchers, id)
+	}
+	return watchers
+}
+
+// presenceFrame describes a user's presence as others may see it
+func presenceFrame(userID uuid.UUID, online bool) (WSMessage, error) {
+	var user models.User
+	if err := database.DB.Select("id", "show_online_status", "last_seen_at").First(&user, "id = ?", userID).Error; err != nil {
+		return WSMessage{}, err
+	}
+
+	frame := WSMessage{
+		Type:      "online_status",
+		UserID:    userID.String(),
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if !user.ShowOnlineStatus {
+		frame.PresenceHidden = true
+		return frame, nil
+	}
+	frame.IsOnline = &online
+	if !online && !user.LastSeenAt.IsZero() {
+		frame.LastSeenAt = user.LastSeenAt.Format(time.RFC3339)
+	}
+	return frame, nil
+}
+
+// publishPresence tells the user's watchers that they came online or went offline. It reads
+// the database, so the Run loop calls it in a goroutine.
+func (h *Hub) publishPresence(userID uuid.UUID, online bool) {
+	watchers := h.presenceWatchers(userID)
+	if len(watchers) == 0 {
+		return
+	}
+
+	frame, err := presenceFrame(userID, online)
+	if err != nil {
+		log.Printf("⚠️ Failed to load presence of %s: %v", userID, err)
+		return
+	}
+	payload, _ := json.Marshal(frame)
+	for _, watcherID := range watchers {
+		h.SendToUser(watcherID, payload)
+	}
+}
+
+// PresenceSettingChanged re-announces a user's presence after they changed ShowOnlineStatus
+func PresenceSettingChanged(userID uuid.UUID) {
+	if hub == nil {
+		return
+	}
+	go hub.publishPresence(userID, hub.IsConnected(userID))
+}
+
+// onlineUsers reports which of the users have a fresh socket on any node. Without Redis it
+// returns nil and callers fall back to users.is_online.
+func onlineUsers(userIDs []uuid.UUID) map[uuid.UUID]bool {
+	if database.RedisClient == nil || len(userIDs) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	stale := wsPresenceStaleScore()
+	pipe := database.RedisClient.Pipeline()
+	counts := make([]*redis.IntCmd, len(userIDs))
+	for i, userID := range userIDs {
+		counts[i] = pipe.ZCount(ctx, wsPresenceKey(userID), stale, "+inf")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil
+	}
+
+	online := make(map[uuid.UUID]bool, len(userIDs))
+	for i, userID := range userIDs {
+		online[userID] = counts[i].Val() > 0
+	}
+	return online
+}
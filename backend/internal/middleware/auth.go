@@ -1,11 +1,30 @@
 package middleware
 
 import (
+	"errors"
 	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/utils"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// telegramInitDataMaxAge matches the window TelegramLogin accepts initData for
+const telegramInitDataMaxAge = time.Hour
+
+var (
+	ErrAuthFormat   = errors.New("invalid authorization header format")
+	ErrInvalidToken = errors.New("invalid or expired token")
+	ErrUserInactive = errors.New("account is not active")
 )
 
 func AuthMiddleware(c *fiber.Ctx) error {
@@ -16,29 +35,206 @@ func AuthMiddleware(c *fiber.Ctx) error {
 		})
 	}
 
-	// Extract token from "Bearer <token>"
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
+	token, err := AuthenticateHeader(authHeader)
+	if err != nil {
+		return authError(c, err)
+	}
+
+	// Store token in context for handlers to use
+	c.Locals("user", token)
+
+	return c.Next()
+}
+
+// WebSocketAuth authenticates a WebSocket upgrade before it is accepted. Browsers cannot set
+// headers on a WebSocket, so besides the Authorization header the credentials may come as
+// ?token=<jwt> or ?tma=<initData>. Deactivated users are refused.
+func WebSocketAuth(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		if tokenString := c.Query("token"); tokenString != "" {
+			authHeader = "Bearer " + tokenString
+		} else if initData := c.Query("tma"); initData != "" {
+			authHeader = "tma " + initData
+		}
+	}
+	if authHeader == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Token required",
+		})
+	}
+
+	token, err := AuthenticateHeader(authHeader)
+	if err == nil {
+		var userID uuid.UUID
+		if userID, err = TokenUserID(token); err == nil {
+			err = CheckUserActive(userID)
+		}
+	}
+	if err != nil {
+		return authError(c, err)
+	}
+
+	c.Locals("user", token)
+
+	return c.Next()
+}
+
+// AdminOnly lets through only users listed in ADMIN_USER_IDS whose account is active.
+// It runs after AuthMiddleware; with no admins configured every request is refused.
+func AdminOnly(c *fiber.Ctx) error {
+	token, ok := c.Locals("user").(*jwt.Token)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Authorization header required"})
+	}
+	userID, err := TokenUserID(token)
+	if err != nil {
+		return authError(c, ErrInvalidToken)
+	}
+	if !isAdmin(userID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "Admin access required"})
+	}
+	if err := CheckUserActive(userID); err != nil {
+		return authError(c, err)
+	}
+	return c.Next()
+}
+
+// isAdmin reports whether the user is listed in ADMIN_USER_IDS
+func isAdmin(userID uuid.UUID) bool {
+	for _, id := range strings.Split(config.Cfg.AdminUserIDs, ",") {
+		if adminID, err := uuid.Parse(strings.TrimSpace(id)); err == nil && adminID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func authError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrAuthFormat):
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"error": "Invalid authorization header format",
 		})
+	case errors.Is(err, ErrUserInactive):
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error": "Account is not active",
+		})
+	default:
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid or expired token",
+		})
+	}
+}
+
+// AuthenticateHeader validates an Authorization value, either "Bearer <jwt>" or
+// "tma <initData>" (Telegram Mini App), and returns a token carrying the user_id and exp claims
+func AuthenticateHeader(authHeader string) (*jwt.Token, error) {
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, ErrAuthFormat
 	}
 
-	tokenString := parts[1]
+	switch parts[0] {
+	case "Bearer":
+		return ParseAccessToken(parts[1])
+	case "tma":
+		return ParseTelegramInitData(parts[1])
+	default:
+		return nil, ErrAuthFormat
+	}
+}
 
-	// Parse and validate token
+// ParseAccessToken validates a JWT issued by the API
+func ParseAccessToken(tokenString string) (*jwt.Token, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.Cfg.JWTSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil || !token.Valid {
-		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-			"error": "Invalid or expired token",
-		})
+		return nil, ErrInvalidToken
 	}
+	if _, err := TokenUserID(token); err != nil {
+		return nil, ErrInvalidToken
+	}
+	return token, nil
+}
 
-	// Store token in context for handlers to use
-	c.Locals("user", token)
+// ParseTelegramInitData validates Mini App initData against the bot token and resolves the
+// registered user. The returned token expires when the initData does.
+func ParseTelegramInitData(initData string) (*jwt.Token, error) {
+	if config.Cfg.TelegramBotToken == "" {
+		return nil, ErrInvalidToken
+	}
 
-	return c.Next()
+	tgUser, err := utils.ValidateTelegramInitData(initData, config.Cfg.TelegramBotToken, telegramInitDataMaxAge)
+	if err != nil || tgUser.ID == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	var user models.User
+	if err := database.DB.Select("id", "is_active").Where("telegram_id = ?", tgUser.ID).First(&user).Error; err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !user.IsActive {
+		return nil, ErrUserInactive
+	}
+
+	expiresAt := time.Now().Add(telegramInitDataMaxAge)
+	if values, err := url.ParseQuery(initData); err == nil {
+		if authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64); err == nil {
+			expiresAt = time.Unix(authDate, 0).Add(telegramInitDataMaxAge)
+		}
+	}
+
+	return &jwt.Token{
+		Header: map[string]interface{}{"typ": "tma"},
+		Claims: jwt.MapClaims{
+			"user_id": user.ID.String(),
+			"exp":     expiresAt.Unix(),
+		},
+		Valid: true,
+	}, nil
+}
+
+// TokenUserID returns the user_id claim of a validated token
+func TokenUserID(token *jwt.Token) (uuid.UUID, error) {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return uuid.Nil, ErrInvalidToken
+	}
+	userIDStr, ok := claims["user_id"].(string)
+	if !ok {
+		return uuid.Nil, ErrInvalidToken
+	}
+	return uuid.Parse(userIDStr)
+}
+
+// TokenExpiry returns when a validated token expires (zero time if it never does)
+func TokenExpiry(token *jwt.Token) time.Time {
+	exp, err := token.Claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return time.Time{}
+	}
+	return exp.Time
+}
+
+// CheckUserActive fails with ErrUserInactive if the user was deactivated or deleted.
+// Other errors are lookup failures and say nothing about the account.
+func CheckUserActive(userID uuid.UUID) error {
+	var user models.User
+	if err := database.DB.Select("id", "is_active").First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserInactive
+		}
+		return err
+	}
+	if !user.IsActive {
+		return ErrUserInactive
+	}
+	return nil
 }
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"encoding/json"
+	"lomi-backend/internal/database"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// IdempotencyKeyHeader is the header clients set to make a retried request safe
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyTTL is how long a stored response is replayed for the same key
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyLockTTL bounds how long a request can hold the key while in flight
+const idempotencyLockTTL = 30 * time.Second
+
+type idempotentResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key. Requests without the header, without a user or without Redis pass through.
+// Only 2xx responses are stored: errors, including rate limits from later handlers, are
+// not replayed, so a retry with the same key runs again.
+func Idempotency(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		idempotencyKey := c.Get(IdempotencyKeyHeader)
+		if idempotencyKey == "" || len(idempotencyKey) > 128 || database.RedisClient == nil {
+			return c.Next()
+		}
+
+		token, ok := c.Locals("user").(*jwt.Token)
+		if !ok {
+			return c.Next()
+		}
+		claims := token.Claims.(jwt.MapClaims)
+		userIDStr, ok := claims["user_id"].(string)
+		if !ok {
+			return c.Next()
+		}
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			return c.Next()
+		}
+
+		ctx := c.Context()
+		key := "idempotency:" + scope + ":" + userID.String() + ":" + idempotencyKey
+		lockKey := key + ":lock"
+
+		// Replay a stored response
+		if stored, err := database.RedisClient.Get(ctx, key).Bytes(); err == nil {
+			var resp idempotentResponse
+			if json.Unmarshal(stored, &resp) == nil {
+				c.Set(fiber.HeaderContentType, resp.ContentType)
+				c.Set("Idempotent-Replayed", "true")
+				return c.Status(resp.Status).Send(resp.Body)
+			}
+		}
+
+		// Only one request per key may run at a time
+		acquired, err := database.RedisClient.SetNX(ctx, lockKey, "1", idempotencyLockTTL).Result()
+		if err != nil {
+			return c.Next() // Redis error, allow request
+		}
+		if !acquired {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "A request with this Idempotency-Key is already in progress",
+			})
+		}
+		defer database.RedisClient.Del(ctx, lockKey)
+
+		if err := c.Next(); err != nil {
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status < fiber.StatusOK || status >= fiber.StatusMultipleChoices {
+			return nil
+		}
+
+		resp := idempotentResponse{
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        append([]byte(nil), c.Response().Body()...),
+		}
+		if encoded, err := json.Marshal(resp); err == nil {
+			database.RedisClient.Set(ctx, key, encoded, idempotencyTTL)
+		}
+
+		return nil
+	}
+}
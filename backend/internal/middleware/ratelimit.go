@@ -1,8 +1,7 @@
 package middleware
 
 import (
-	"lomi-backend/internal/database"
-	"strconv"
+	"lomi-backend/internal/ratelimit"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -11,11 +10,7 @@ import (
 )
 
 // RateLimitConfig configures rate limiting
-type RateLimitConfig struct {
-	MaxRequests int           // Maximum number of requests
-	Window      time.Duration // Time window
-	KeyPrefix   string        // Redis key prefix
-}
+type RateLimitConfig = ratelimit.Config
 
 // RateLimit creates a rate limiting middleware
 func RateLimit(config RateLimitConfig) fiber.Handler {
@@ -42,41 +37,12 @@ func RateLimit(config RateLimitConfig) fiber.Handler {
 			return c.Next()
 		}
 
-		// Create Redis key
-		key := config.KeyPrefix + ":" + userID.String()
-		windowSeconds := int(config.Window.Seconds())
-
-		// Use Redis to track rate limits
-		if database.RedisClient != nil {
-			// Get current count
-			countStr, err := database.RedisClient.Get(c.Context(), key).Result()
-			if err != nil && err.Error() != "redis: nil" {
-				// Redis error, allow request but log
-				return c.Next()
-			}
-
-			count := 0
-			if countStr != "" {
-				count, _ = strconv.Atoi(countStr)
-			}
-
-			// Check if limit exceeded
-			if count >= config.MaxRequests {
-				return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-					"error": "Rate limit exceeded",
-					"retry_after": windowSeconds,
-				})
-			}
-
-			// Increment counter
-			pipe := database.RedisClient.Pipeline()
-			pipe.Incr(c.Context(), key)
-			pipe.Expire(c.Context(), key, config.Window)
-			_, err = pipe.Exec(c.Context())
-			if err != nil {
-				// Redis error, allow request
-				return c.Next()
-			}
+		allowed, err := ratelimit.Allow(c.Context(), config, userID)
+		if err == nil && !allowed {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Rate limit exceeded",
+				"retry_after": int(config.Window.Seconds()),
+			})
 		}
 
 		return c.Next()
@@ -94,11 +60,7 @@ func SwipeRateLimit() fiber.Handler {
 
 // MessageRateLimit limits messages per minute
 func MessageRateLimit() fiber.Handler {
-	return RateLimit(RateLimitConfig{
-		MaxRequests: 30, // Max 30 messages per minute
-		Window:      time.Minute,
-		KeyPrefix:   "ratelimit:message",
-	})
+	return RateLimit(ratelimit.Message)
 }
 
 // PurchaseRateLimit limits coin purchases per day
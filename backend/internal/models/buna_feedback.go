@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BunaFeedback is one participant's answer to the "how was it?" prompt after a buna. It is
+// kept apart from the invite message, which both users can read: the other person must not
+// learn that they were rated or reported.
+type BunaFeedback struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey" json:"id"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_buna_feedback_message_user" json:"message_id"` // The buna_invite message
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_buna_feedback_message_user" json:"user_id"`    // Who answered
+	MatchID   uuid.UUID `gorm:"type:uuid;not null;index" json:"match_id"`
+
+	Rating   int        `gorm:"default:0" json:"rating,omitempty"` // 1-5
+	ShowedUp *bool      `json:"showed_up,omitempty"`
+	FeltSafe *bool      `json:"felt_safe,omitempty"`
+	Comment  string     `gorm:"type:text" json:"comment,omitempty"`
+	ReportID *uuid.UUID `gorm:"type:uuid" json:"report_id,omitempty"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()" json:"created_at"`
+}
+
+func (BunaFeedback) TableName() string {
+	return "buna_feedback"
+}
+
+func (f *BunaFeedback) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ChatMediaStatus string
+
+const (
+	ChatMediaStatusPending  ChatMediaStatus = "pending"  // Upload URL handed out
+	ChatMediaStatusAttached ChatMediaStatus = "attached" // Sent in a message
+)
+
+// ChatMedia is a file uploaded to the private chat bucket for one match.
+// Messages store the object key; participants get short-lived signed URLs.
+type ChatMedia struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MatchID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	UploaderID uuid.UUID `gorm:"type:uuid;not null;index"`
+
+	MediaType       MessageType `gorm:"type:varchar(20);not null"` // photo, video or voice
+	ObjectKey       string      `gorm:"type:text;not null;uniqueIndex"`
+	ContentType     string      `gorm:"type:varchar(100);not null"`
+	SizeBytes       int64       `gorm:"not null"`
+	DurationSeconds int         `gorm:"default:0"` // Video and voice only
+
+	Status    ChatMediaStatus `gorm:"type:varchar(20);default:'pending';index"`
+	MessageID *uuid.UUID      `gorm:"type:uuid"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (ChatMedia) TableName() string {
+	return "chat_media"
+}
+
+func (m *ChatMedia) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ScreeningAction is what the chat content filter does with a message, from least to most severe
+type ScreeningAction string
+
+const (
+	ScreeningActionAllow ScreeningAction = "allow" // Deliver as usual
+	ScreeningActionWarn  ScreeningAction = "warn"  // Deliver with a safety warning for the receiver
+	ScreeningActionHold  ScreeningAction = "hold"  // Store, but deliver only after an admin releases it
+	ScreeningActionBlock ScreeningAction = "block" // Reject the send
+)
+
+type FlaggedMessageDecision string
+
+const (
+	FlaggedMessageDecisionRelease FlaggedMessageDecision = "release" // Deliver a held message
+	FlaggedMessageDecisionReject  FlaggedMessageDecision = "reject"  // Keep a held message from the receiver for good
+	FlaggedMessageDecisionDismiss FlaggedMessageDecision = "dismiss" // Nothing to do (warned or blocked messages)
+)
+
+// FlaggedMessage records a message the content filter matched, for admin review. Content is
+// a copy taken at send time; MessageID is nil when the send was blocked.
+type FlaggedMessage struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MessageID  *uuid.UUID `gorm:"type:uuid;index"`
+	MatchID    uuid.UUID  `gorm:"type:uuid;not null"`
+	SenderID   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Sender     User       `gorm:"foreignKey:SenderID"`
+	ReceiverID uuid.UUID  `gorm:"type:uuid;not null"`
+
+	MessageType MessageType     `gorm:"type:varchar(20);not null"`
+	Content     string          `gorm:"type:text"`
+	Action      ScreeningAction `gorm:"type:varchar(10);not null;index"`
+	Rules       JSONStringArray `gorm:"type:jsonb;default:'[]'"` // Rules that matched
+	Matches     JSONStringArray `gorm:"type:jsonb;default:'[]'"` // The matched text, per rule
+
+	IsReviewed bool                   `gorm:"default:false;index"`
+	ReviewedBy *uuid.UUID             `gorm:"type:uuid"`
+	ReviewedAt *time.Time             `gorm:"type:timestamptz"`
+	Decision   FlaggedMessageDecision `gorm:"type:varchar(10)"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (f *FlaggedMessage) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return
+}
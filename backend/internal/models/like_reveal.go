@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LikeRevealSource string
+
+const (
+	LikeRevealSourceFree   LikeRevealSource = "free"   // The daily free reveal
+	LikeRevealSourceSingle LikeRevealSource = "single" // One liker, paid
+	LikeRevealSourceAll    LikeRevealSource = "all"    // Everyone pending at the time, paid
+)
+
+// LikeReveal records that a user unlocked a liker on "Who Likes You". A reveal is kept for
+// good, so the liker stays visible on later visits.
+type LikeReveal struct {
+	ID      uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_like_reveals_user_liker"`
+	LikerID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_like_reveals_user_liker"`
+
+	Source LikeRevealSource `gorm:"type:varchar(20);not null"`
+	// The charge that paid for it (shared by every liker of a reveal-all)
+	CoinTransactionID *uuid.UUID `gorm:"type:uuid"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (r *LikeReveal) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
@@ -7,6 +7,16 @@ import (
 	"gorm.io/gorm"
 )
 
+type MatchStatus string
+
+const (
+	MatchStatusActive    MatchStatus = "active"
+	MatchStatusUnmatched MatchStatus = "unmatched"
+	MatchStatusExpired   MatchStatus = "expired"
+	MatchStatusBlocked   MatchStatus = "blocked"
+	MatchStatusRewound   MatchStatus = "rewound" // The completing like was rewound
+)
+
 type Match struct {
 	ID      uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
 	User1ID uuid.UUID `gorm:"type:uuid;not null;index"`
@@ -17,10 +27,26 @@ type Match struct {
 	InitiatedBy uuid.UUID `gorm:"type:uuid;not null"`
 	Initiator   User      `gorm:"foreignKey:InitiatedBy"`
 
-	IsActive   bool       `gorm:"default:true;index"`
+	IsActive   bool       `gorm:"default:true;index"` // Kept in sync with Status == active
 	UnmatchedBy *uuid.UUID `gorm:"type:uuid"`
 	UnmatchedAt *time.Time `gorm:"type:timestamptz"`
 
+	// Lifecycle: a rematch reuses the row and starts a new epoch; messages from
+	// earlier epochs stay in the table but are no longer shown
+	Status         MatchStatus `gorm:"type:varchar(20);default:'active';index"`
+	Epoch          int         `gorm:"default:1"`
+	EpochStartedAt time.Time   `gorm:"type:timestamptz;default:now()"`
+
+	// Expiry: an epoch with no first message expires at ExpiresAt (nil = never)
+	ExpiresAt          *time.Time `gorm:"type:timestamptz;index"`
+	FirstMessageAt     *time.Time `gorm:"type:timestamptz"`
+	ExpiryReminderSent bool       `gorm:"default:false"`
+	ExtensionCount     int        `gorm:"default:0"`
+
+	// Last sequence number handed out in this match, to a new message or a change to one
+	// (see Message.Seq and Message.UpdatedSeq)
+	LastSeq int64 `gorm:"not null;default:0"`
+
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
 }
 
@@ -35,3 +61,34 @@ func (m *Match) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+
+type MatchEventType string
+
+const (
+	MatchEventMatched   MatchEventType = "matched"
+	MatchEventUnmatched MatchEventType = "unmatched"
+	MatchEventRematched MatchEventType = "rematched"
+	MatchEventExpired   MatchEventType = "expired"
+	MatchEventBlocked   MatchEventType = "blocked"
+	MatchEventRewound   MatchEventType = "rewound"
+)
+
+// MatchEvent is the lifecycle history of a match
+type MatchEvent struct {
+	ID      uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MatchID uuid.UUID  `gorm:"type:uuid;not null;index"`
+	Match   Match      `gorm:"foreignKey:MatchID"`
+	ActorID *uuid.UUID `gorm:"type:uuid;index"` // Nil for system events (e.g. expiry)
+
+	EventType MatchEventType `gorm:"type:varchar(20);not null"`
+	Epoch     int            `gorm:"not null"`
+
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (e *MatchEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
@@ -22,6 +22,7 @@ type Media struct {
 	MediaType     MediaType `gorm:"type:media_type;not null"`
 	URL           string    `gorm:"type:text;not null"`
 	ThumbnailURL  string    `gorm:"type:text"`
+	BlurPreview   string    `gorm:"type:text"` // Data URI, see services.LikePreviews
 	DurationSeconds int     `gorm:"type:integer"`
 
 	DisplayOrder int `gorm:"default:1;index"`
@@ -17,6 +17,7 @@ const (
 	MessageTypeSticker   MessageType = "sticker"
 	MessageTypeGift      MessageType = "gift"
 	MessageTypeBunaInvite MessageType = "buna_invite"
+	MessageTypePromptReply MessageType = "prompt_reply"
 )
 
 type Message struct {
@@ -37,9 +38,33 @@ type Message struct {
 
 	Metadata JSONMap `gorm:"type:jsonb;default:'{}'"`
 
+	// Match epoch the message was sent in (see Match.Epoch)
+	Epoch int `gorm:"default:1;index"`
+
+	// Seq orders messages within a match (1, 2, 3, ... across epochs); clients sync from
+	// the last seq they have. ClientMessageID is the sender's ID for the message so a
+	// retried send returns the original row instead of a duplicate.
+	Seq             int64   `gorm:"not null;default:0"`
+	ClientMessageID *string `gorm:"type:varchar(64)"`
+
+	// UpdatedSeq is the match sequence number taken by the message's latest change: its
+	// creation, an edit, a delete for everyone, a reaction, a buna update or a release by a
+	// moderator. Sync returns messages whose UpdatedSeq is past the client's cursor.
+	UpdatedSeq int64 `gorm:"not null;default:0"`
+
 	IsRead bool       `gorm:"default:false;index"`
 	ReadAt *time.Time `gorm:"type:timestamptz"`
 
+	// EditedAt is set on every edit (earlier versions are in message_edits). A message
+	// deleted for everyone stays as a tombstone: content, media and metadata are cleared.
+	EditedAt             *time.Time `gorm:"type:timestamptz"`
+	DeletedForEveryoneAt *time.Time `gorm:"type:timestamptz"`
+
+	Reactions []MessageReaction `gorm:"foreignKey:MessageID"`
+
+	// Held by the content filter: only the sender sees it until an admin releases it
+	Held bool `gorm:"default:false"`
+
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
 	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()"`
 }
@@ -48,6 +73,30 @@ func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
 	if m.ID == uuid.Nil {
 		m.ID = uuid.New()
 	}
+	// Take the next sequence number and stamp the match's current epoch. The row lock
+	// on the match is held until the insert commits, so seqs become visible in order.
+	var seq int64
+	var epoch int
+	row := tx.Session(&gorm.Session{NewDB: true}).
+		Raw("UPDATE matches SET last_seq = last_seq + 1 WHERE id = ? RETURNING last_seq, epoch", m.MatchID).
+		Row()
+	if err = row.Scan(&seq, &epoch); err != nil {
+		return err
+	}
+	m.Seq = seq
+	m.UpdatedSeq = seq
+	if m.Epoch == 0 {
+		if epoch == 0 {
+			epoch = 1
+		}
+		m.Epoch = epoch
+	}
 	return
 }
 
+// AfterCreate records the first message of the match's current epoch, which stops it expiring
+func (m *Message) AfterCreate(tx *gorm.DB) (err error) {
+	return tx.Session(&gorm.Session{NewDB: true}).Model(&Match{}).
+		Where("id = ? AND epoch = ? AND first_message_at IS NULL", m.MatchID, m.Epoch).
+		Update("first_message_at", m.CreatedAt).Error
+}
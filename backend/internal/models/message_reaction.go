@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MessageReaction is one user's emoji on a message. Each user has at most one reaction
+// per message; reacting again replaces it.
+type MessageReaction struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MessageID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_message_reactions_user"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_message_reactions_user"`
+	Emoji     string    `gorm:"type:varchar(32);not null"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (r *MessageReaction) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return
+}
+
+// MessageEdit keeps the content a message had before an edit
+type MessageEdit struct {
+	ID              uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	MessageID       uuid.UUID `gorm:"type:uuid;not null;index"`
+	EditorID        uuid.UUID `gorm:"type:uuid;not null"`
+	PreviousContent string    `gorm:"type:text"`
+	EditedAt        time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (e *MessageEdit) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return
+}
+
+// MessageDeletion hides a message from one participant only ("delete for me")
+type MessageDeletion struct {
+	MessageID uuid.UUID `gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Prompt is an icebreaker question from the admin-managed catalogue, in English and
+// Amharic. Retired prompts are deactivated rather than deleted so answers keep their text.
+type Prompt struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	Category     string    `gorm:"size:50"`
+	TextEN       string    `gorm:"column:text_en;type:text;not null"`
+	TextAM       string    `gorm:"column:text_am;type:text;not null"`
+	IsActive     bool      `gorm:"default:true;index"`
+	DisplayOrder int       `gorm:"default:0"`
+	CreatedAt    time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt    time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (p *Prompt) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return
+}
+
+// ProfilePrompt is a user's answer to a prompt, shown on their profile and swipe card.
+// Position orders a user's answers (0, 1, 2).
+type ProfilePrompt struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_profile_prompts_user_prompt"`
+	PromptID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_profile_prompts_user_prompt"`
+	Prompt    Prompt    `gorm:"foreignKey:PromptID"`
+	Answer    string    `gorm:"type:text;not null"`
+	Position  int       `gorm:"not null;default:0"`
+	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+	UpdatedAt time.Time `gorm:"type:timestamptz;default:now()"`
+}
+
+func (p *ProfilePrompt) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return
+}
@@ -29,6 +29,10 @@ type Report struct {
 
 	ScreenshotURLs JSONStringArray `gorm:"type:jsonb;default:'[]'"`
 
+	// Conversation the report is about, with a transcript snapshot in the chat bucket
+	MatchID       *uuid.UUID `gorm:"type:uuid"`
+	TranscriptKey string     `gorm:"type:text"`
+
 	IsReviewed bool       `gorm:"default:false;index"`
 	ReviewedBy  *uuid.UUID `gorm:"type:uuid"`
 	ReviewedAt  *time.Time `gorm:"type:timestamptz"`
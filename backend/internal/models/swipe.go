@@ -24,6 +24,10 @@ type Swipe struct {
 
 	Action SwipeAction `gorm:"type:swipe_action;not null"`
 
+	// A like can reply to one of the swiped user's prompts; the reply opens the chat on match
+	PromptAnswerID *uuid.UUID `gorm:"type:uuid"`
+	PromptComment  string     `gorm:"type:text"`
+
 	CreatedAt time.Time `gorm:"type:timestamptz;default:now()"`
 }
 
@@ -19,6 +19,9 @@ const (
 	TransactionTypeRefund                    TransactionType = "refund"
 	TransactionTypeChannelSubscriptionReward TransactionType = "channel_subscription_reward"
 	TransactionTypeReveal                    TransactionType = "reveal"
+	TransactionTypeRewind                    TransactionType = "rewind"
+	TransactionTypeSuperLike                 TransactionType = "super_like"
+	TransactionTypeMatchExtend               TransactionType = "match_extend"
 
 	PaymentMethodTelebirr  PaymentMethod = "telebirr"
 	PaymentMethodCbeBirr   PaymentMethod = "cbe_birr"
@@ -119,6 +119,10 @@ type User struct {
 	DailyFreeRevealUsed bool      `gorm:"default:false"`
 	LastRevealDate      time.Time `gorm:"type:date"`
 
+	// Daily Free Super Likes (reset at midnight Addis time)
+	DailySuperLikesUsed int       `gorm:"default:0"`
+	LastSuperLikeDate   time.Time `gorm:"type:date"`
+
 	// Onboarding Progress
 	// 0 = fresh (just logged in)
 	// 1 = age & gender done
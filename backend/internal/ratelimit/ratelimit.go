@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"lomi-backend/internal/database"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Config configures a per-user rate limit
+type Config struct {
+	MaxRequests int           // Maximum number of requests
+	Window      time.Duration // Time window
+	KeyPrefix   string        // Redis key prefix
+}
+
+// Message limits chat messages per minute. Enforced by the message pipeline
+// (services.SendChatMessage) for REST and WebSocket sends alike.
+var Message = Config{
+	MaxRequests: 30, // Max 30 messages per minute
+	Window:      time.Minute,
+	KeyPrefix:   "ratelimit:message",
+}
+
+// Allow counts one request for the user against config and reports whether it is within
+// the limit. Without Redis, or on a Redis error (returned), requests are allowed.
+func Allow(ctx context.Context, config Config, userID uuid.UUID) (bool, error) {
+	if database.RedisClient == nil {
+		return true, nil
+	}
+
+	// Create Redis key
+	key := config.KeyPrefix + ":" + userID.String()
+
+	// Get current count
+	countStr, err := database.RedisClient.Get(ctx, key).Result()
+	if err != nil && err != redis.Nil {
+		// Redis error, allow request
+		return true, err
+	}
+
+	count := 0
+	if countStr != "" {
+		count, _ = strconv.Atoi(countStr)
+	}
+
+	// Check if limit exceeded
+	if count >= config.MaxRequests {
+		return false, nil
+	}
+
+	// Increment counter
+	pipe := database.RedisClient.Pipeline()
+	pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, config.Window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return true, err
+	}
+	return true, nil
+}
@@ -44,6 +44,9 @@ func SetupRoutes(app *fiber.App) {
 	// User Profile
 	protected.Get("/users/me", handlers.GetMe)
 	protected.Put("/users/me", handlers.UpdateProfile)
+	protected.Get("/users/me/prompts", handlers.GetMyPrompts)
+	protected.Put("/users/me/prompts", handlers.UpdateMyPrompts)
+	protected.Get("/prompts", handlers.GetPrompts)
 	protected.Get("/users", handlers.GetAllUsers) // List all users (for testing)
 
 	// Onboarding
@@ -60,19 +63,39 @@ func SetupRoutes(app *fiber.App) {
 
 	// Discovery & Swiping (with rate limiting)
 	protected.Get("/discover/swipe", handlers.GetSwipeCards)
-	protected.Post("/discover/swipe", middleware.SwipeRateLimit(), handlers.SwipeAction)
+	protected.Post("/discover/swipe", middleware.Idempotency("swipe"), middleware.SwipeRateLimit(), handlers.SwipeAction)
+	protected.Post("/discover/rewind", handlers.RewindSwipe) // Undo last swipe (costs coins)
+
+	// Boosts
+	protected.Post("/boost", middleware.PurchaseRateLimit(), handlers.ActivateBoost)
+	protected.Get("/boost", handlers.GetBoostStatus)
 	protected.Get("/discover/feed", handlers.GetExploreFeed)
 
 	// Matches
 	protected.Get("/matches", handlers.GetMatches)
+	protected.Get("/matches/unmatched", handlers.GetUnmatchedHistory)
 	protected.Get("/matches/:id", handlers.GetMatchDetails)
 	protected.Delete("/matches/:id", handlers.Unmatch)
+	protected.Post("/matches/:id/extend", handlers.ExtendMatch) // Push back expiry (costs coins)
 
-	// Chat (with rate limiting for messages)
+	// Chat
 	protected.Get("/chats", handlers.GetChats)
+	protected.Post("/chats/sync", handlers.SyncChats)
+	protected.Get("/chats/search", handlers.SearchMessages)
 	protected.Get("/chats/:id/messages", handlers.GetMessages)
-	protected.Post("/chats/:id/messages", middleware.MessageRateLimit(), handlers.SendMessage)
+	protected.Post("/chats/:id/messages", handlers.SendMessage) // Rate limited in services.SendChatMessage
+	protected.Put("/chats/:id/messages/:message_id", handlers.EditMessage)
+	protected.Delete("/chats/:id/messages/:message_id", handlers.DeleteMessage)
+	protected.Get("/chats/:id/messages/:message_id/edits", handlers.GetMessageEdits)
+	protected.Put("/chats/:id/messages/:message_id/reaction", handlers.ReactToMessage)
+	protected.Delete("/chats/:id/messages/:message_id/reaction", handlers.RemoveReaction)
 	protected.Put("/chats/:id/read", handlers.MarkMessagesAsRead)
+	protected.Post("/chats/:id/media/upload-url", handlers.GetChatMediaUploadURL)
+	protected.Get("/chats/:id/media/:media_id", handlers.GetChatMediaURL)
+	protected.Post("/chats/:id/buna", handlers.ProposeBuna)
+	protected.Put("/chats/:id/buna/:message_id", handlers.UpdateBuna)
+	protected.Post("/chats/:id/buna/:message_id/feedback", handlers.SubmitBunaFeedback)
+	protected.Get("/chats/:id/export", handlers.ExportChat)
 
 	// Gifts (Luxury System)
 	protected.Get("/gifts/shop", handlers.GetGiftShop)
@@ -123,10 +146,17 @@ func SetupRoutes(app *fiber.App) {
 	protected.Get("/likes/pending", handlers.GetPendingLikes)
 	protected.Post("/likes/reveal", handlers.RevealLike)
 
-	// Admin routes (should have admin middleware in production)
-	admin := protected.Group("/admin")
+	// Admin routes (users listed in ADMIN_USER_IDS only)
+	admin := protected.Group("/admin", middleware.AdminOnly)
 	admin.Get("/reports/pending", handlers.GetPendingReports)
 	admin.Put("/reports/:id/review", handlers.ReviewReport)
+	admin.Get("/reports/:id/transcript", handlers.GetReportTranscript)
+	admin.Get("/messages/flagged", handlers.GetFlaggedMessages)
+	admin.Put("/messages/flagged/:id/review", handlers.ReviewFlaggedMessage)
+	admin.Get("/prompts", handlers.GetAdminPrompts)
+	admin.Post("/prompts", handlers.CreatePrompt)
+	admin.Put("/prompts/:id", handlers.UpdatePrompt)
+	admin.Delete("/prompts/:id", handlers.DeletePrompt)
 	admin.Get("/payouts/pending", handlers.GetPendingPayouts)
 	admin.Put("/payouts/:id/process", handlers.ProcessPayout)
 
@@ -136,6 +166,6 @@ func SetupRoutes(app *fiber.App) {
 	admin.Put("/moderation/rejected/:id/verify", handlers.VerifyRejectedPhoto)
 	admin.Delete("/moderation/rejected/:id", handlers.DeleteRejectedPhoto)
 
-	// WebSocket (handles auth internally)
-	api.Get("/ws", websocket.New(handlers.HandleWebSocket))
+	// WebSocket (authenticated before the upgrade)
+	api.Get("/ws", middleware.WebSocketAuth, websocket.New(handlers.HandleWebSocket))
 }
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lomi-backend/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Boost state lives in Redis only:
+//
+//	boost:{user_id}          hash: city, started_at, expires_at, views, likes
+//	boost:active:{city}      sorted set of boosted user IDs scored by expiry (unix)
+//
+// The hash outlives the boost by BoostSummaryTTL so the post-boost summary can be shown.
+
+const (
+	BoostDuration   = 30 * time.Minute
+	BoostSummaryTTL = 24 * time.Hour
+)
+
+var (
+	ErrBoostUnavailable = errors.New("boost storage not available")
+	ErrBoostActive      = errors.New("boost already active")
+)
+
+// Boost is a user's current or most recent boost
+type Boost struct {
+	UserID    uuid.UUID `json:"user_id"`
+	City      string    `json:"city"`
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Views     int64     `json:"views"` // Times the profile was shown while boosted
+	Likes     int64     `json:"likes"` // Likes received while boosted
+}
+
+// IsActive reports whether the boost is still running
+func (b *Boost) IsActive() bool {
+	return time.Now().Before(b.ExpiresAt)
+}
+
+func boostKey(userID uuid.UUID) string {
+	return fmt.Sprintf("boost:%s", userID.String())
+}
+
+func boostCityKey(city string) string {
+	return fmt.Sprintf("boost:active:%s", strings.ToLower(strings.TrimSpace(city)))
+}
+
+// GetBoost returns the user's current or most recent boost, or nil if there is none
+func GetBoost(userID uuid.UUID) (*Boost, error) {
+	if database.RedisClient == nil {
+		return nil, ErrBoostUnavailable
+	}
+
+	values, err := database.RedisClient.HGetAll(context.Background(), boostKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	boost := &Boost{UserID: userID, City: values["city"]}
+	if ts, err := strconv.ParseInt(values["started_at"], 10, 64); err == nil {
+		boost.StartedAt = time.Unix(ts, 0)
+	}
+	if ts, err := strconv.ParseInt(values["expires_at"], 10, 64); err == nil {
+		boost.ExpiresAt = time.Unix(ts, 0)
+	}
+	boost.Views, _ = strconv.ParseInt(values["views"], 10, 64)
+	boost.Likes, _ = strconv.ParseInt(values["likes"], 10, 64)
+
+	return boost, nil
+}
+
+// ActivateBoost starts a boost for the user in their city
+func ActivateBoost(userID uuid.UUID, city string) (*Boost, error) {
+	if database.RedisClient == nil {
+		return nil, ErrBoostUnavailable
+	}
+
+	current, err := GetBoost(userID)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.IsActive() {
+		return current, ErrBoostActive
+	}
+
+	now := time.Now()
+	boost := &Boost{
+		UserID:    userID,
+		City:      city,
+		StartedAt: now,
+		ExpiresAt: now.Add(BoostDuration),
+	}
+
+	ctx := context.Background()
+	key := boostKey(userID)
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Del(ctx, key)
+	pipe.HSet(ctx, key,
+		"city", city,
+		"started_at", boost.StartedAt.Unix(),
+		"expires_at", boost.ExpiresAt.Unix(),
+		"views", 0,
+		"likes", 0,
+	)
+	pipe.Expire(ctx, key, BoostDuration+BoostSummaryTTL)
+	pipe.ZAdd(ctx, boostCityKey(city), redis.Z{Score: float64(boost.ExpiresAt.Unix()), Member: userID.String()})
+	pipe.Expire(ctx, boostCityKey(city), BoostDuration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to store boost: %w", err)
+	}
+
+	return boost, nil
+}
+
+// CancelBoost removes a boost that could not be paid for
+func CancelBoost(userID uuid.UUID, city string) error {
+	if database.RedisClient == nil {
+		return ErrBoostUnavailable
+	}
+
+	ctx := context.Background()
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Del(ctx, boostKey(userID))
+	pipe.ZRem(ctx, boostCityKey(city), userID.String())
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ActiveBoostedUsers returns users currently boosted in a city, most recent boost first
+func ActiveBoostedUsers(city string) ([]uuid.UUID, error) {
+	if database.RedisClient == nil {
+		return nil, ErrBoostUnavailable
+	}
+
+	ctx := context.Background()
+	key := boostCityKey(city)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	// Drop expired boosts first
+	if err := database.RedisClient.ZRemRangeByScore(ctx, key, "-inf", now).Err(); err != nil {
+		return nil, err
+	}
+
+	members, err := database.RedisClient.ZRevRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		if id, err := uuid.Parse(m); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// RecordBoostViews counts a profile view for every shown user that is currently boosted
+func RecordBoostViews(userIDs []uuid.UUID) {
+	recordBoostCounter(userIDs, "views")
+}
+
+// RecordBoostLike counts a like received by a currently boosted user
+func RecordBoostLike(userID uuid.UUID) {
+	recordBoostCounter([]uuid.UUID{userID}, "likes")
+}
+
+func recordBoostCounter(userIDs []uuid.UUID, field string) {
+	if database.RedisClient == nil || len(userIDs) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	pipe := database.RedisClient.Pipeline()
+	expiries := make([]*redis.StringCmd, len(userIDs))
+	for i, id := range userIDs {
+		expiries[i] = pipe.HGet(ctx, boostKey(id), "expires_at")
+	}
+	pipe.Exec(ctx) // Missing hashes return redis.Nil per command
+
+	now := time.Now().Unix()
+	incr := database.RedisClient.Pipeline()
+	for i, cmd := range expiries {
+		expiresAt, err := cmd.Int64()
+		if err != nil || expiresAt <= now {
+			continue
+		}
+		incr.HIncrBy(ctx, boostKey(userIDs[i]), field, 1)
+	}
+	incr.Exec(ctx)
+}
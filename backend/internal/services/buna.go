@@ -0,0 +1,394 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// A buna invite is a chat message of type buna_invite. Its state lives in Message.Metadata
+// (see BunaInvite) and changes through UpdateBunaInvite; every change is sent to both
+// participants as a "buna_update" WebSocket event.
+
+type BunaStatus string
+
+const (
+	BunaStatusProposed    BunaStatus = "proposed"
+	BunaStatusAccepted    BunaStatus = "accepted"
+	BunaStatusDeclined    BunaStatus = "declined"
+	BunaStatusRescheduled BunaStatus = "rescheduled" // A new time was proposed; waiting for the other user
+	BunaStatusCancelled   BunaStatus = "cancelled"
+)
+
+type BunaAction string
+
+const (
+	BunaActionAccept     BunaAction = "accept"
+	BunaActionDecline    BunaAction = "decline"
+	BunaActionReschedule BunaAction = "reschedule"
+	BunaActionCancel     BunaAction = "cancel"
+)
+
+const (
+	bunaMinLeadTime    = 30 * time.Minute    // Earliest a buna can be proposed for
+	bunaMaxLeadTime    = 30 * 24 * time.Hour // Latest a buna can be proposed for
+	bunaFeedbackWindow = 7 * 24 * time.Hour  // Feedback is accepted this long after the buna
+	maxCafeNameLength  = 120
+	maxBunaNoteLength  = 500
+)
+
+// bunaLocation is Addis Ababa time (no DST), used in notification texts
+var bunaLocation = time.FixedZone("EAT", 3*60*60)
+
+var (
+	ErrBunaNotFound = errors.New("buna invite not found")
+	ErrBunaState    = errors.New("buna invite cannot change")
+)
+
+// BunaProposal is the when and where of a buna, as sent by a client
+type BunaProposal struct {
+	ScheduledAt time.Time `json:"scheduled_at"`
+	CafeName    string    `json:"cafe_name"`
+	CafeAddress string    `json:"cafe_address,omitempty"`
+	Latitude    *float64  `json:"latitude,omitempty"`
+	Longitude   *float64  `json:"longitude,omitempty"`
+	Note        string    `json:"note,omitempty"`
+}
+
+// BunaHistoryEntry records one state change
+type BunaHistoryEntry struct {
+	Status      BunaStatus `json:"status"`
+	By          uuid.UUID  `json:"by"`
+	At          time.Time  `json:"at"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+}
+
+// BunaInvite is the state stored in a buna_invite message's metadata
+type BunaInvite struct {
+	BunaProposal
+	Status          BunaStatus         `json:"status"`
+	ProposedBy      uuid.UUID          `json:"proposed_by"` // Who made the current proposal (the other user answers)
+	UpdatedAt       time.Time          `json:"updated_at"`
+	RescheduleCount int                `json:"reschedule_count"`
+	History         []BunaHistoryEntry `json:"history"`
+
+	ReminderSentAt       *time.Time `json:"reminder_sent_at,omitempty"`
+	FeedbackPromptSentAt *time.Time `json:"feedback_prompt_sent_at,omitempty"`
+}
+
+// BunaFromMetadata decodes the invite stored in a message
+func BunaFromMetadata(m models.JSONMap) (*BunaInvite, error) {
+	encoded, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var invite BunaInvite
+	if err := json.Unmarshal(encoded, &invite); err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (b *BunaInvite) metadata() models.JSONMap {
+	encoded, _ := json.Marshal(b)
+	var m models.JSONMap
+	json.Unmarshal(encoded, &m)
+	return m
+}
+
+func (b *BunaInvite) record(status BunaStatus, by uuid.UUID, now time.Time) {
+	b.Status = status
+	b.UpdatedAt = now
+	b.History = append(b.History, BunaHistoryEntry{Status: status, By: by, At: now, ScheduledAt: b.ScheduledAt})
+}
+
+// validateBunaProposal checks and cleans a proposed time and place
+func validateBunaProposal(p *BunaProposal) error {
+	p.CafeName = strings.TrimSpace(p.CafeName)
+	p.CafeAddress = strings.TrimSpace(p.CafeAddress)
+	p.Note = strings.TrimSpace(p.Note)
+
+	if p.ScheduledAt.IsZero() {
+		return invalidMessage("scheduled_at is required")
+	}
+	now := time.Now()
+	if p.ScheduledAt.Before(now.Add(bunaMinLeadTime)) {
+		return invalidMessage("buna must be at least 30 minutes from now")
+	}
+	if p.ScheduledAt.After(now.Add(bunaMaxLeadTime)) {
+		return invalidMessage("buna must be within the next 30 days")
+	}
+	p.ScheduledAt = p.ScheduledAt.UTC()
+
+	if p.CafeName == "" {
+		return invalidMessage("cafe_name is required")
+	}
+	if utf8.RuneCountInString(p.CafeName) > maxCafeNameLength || utf8.RuneCountInString(p.CafeAddress) > maxCafeNameLength*2 {
+		return invalidMessage("cafe name or address is too long")
+	}
+	if utf8.RuneCountInString(p.Note) > maxBunaNoteLength {
+		return invalidMessage("note is too long")
+	}
+	if (p.Latitude == nil) != (p.Longitude == nil) {
+		return invalidMessage("latitude and longitude go together")
+	}
+	if p.Latitude != nil && (*p.Latitude < -90 || *p.Latitude > 90 || *p.Longitude < -180 || *p.Longitude > 180) {
+		return invalidMessage("invalid cafe location")
+	}
+	return nil
+}
+
+// newBunaInviteMetadata builds the metadata of a new invite from what the client sent
+func newBunaInviteMetadata(proposerID uuid.UUID, raw map[string]interface{}) (models.JSONMap, error) {
+	var proposal BunaProposal
+	encoded, _ := json.Marshal(raw)
+	if err := json.Unmarshal(encoded, &proposal); err != nil {
+		return nil, invalidMessage("invalid buna details")
+	}
+	if err := validateBunaProposal(&proposal); err != nil {
+		return nil, err
+	}
+
+	invite := BunaInvite{
+		BunaProposal: proposal,
+		ProposedBy:   proposerID,
+		History:      []BunaHistoryEntry{},
+	}
+	invite.record(BunaStatusProposed, proposerID, time.Now())
+	return invite.metadata(), nil
+}
+
+// ProposeBuna sends a buna invite in the match (through the regular message pipeline)
+func ProposeBuna(senderID, matchID uuid.UUID, proposal BunaProposal, clientMessageID string) (*models.Message, bool, error) {
+	raw := map[string]interface{}{}
+	encoded, _ := json.Marshal(proposal)
+	json.Unmarshal(encoded, &raw)
+
+	return SendChatMessage(senderID, SendMessageInput{
+		MatchID:         matchID,
+		MessageType:     models.MessageTypeBunaInvite,
+		Content:         proposal.Note,
+		Metadata:        raw,
+		ClientMessageID: clientMessageID,
+	})
+}
+
+// UpdateBunaInvite applies an action to an invite in an active match:
+//   - accept / decline: only the user who did not make the current proposal
+//   - reschedule: either user proposes a new time (and optionally place); the other answers
+//   - cancel: either user, before the buna time
+func UpdateBunaInvite(actorID, matchID, messageID uuid.UUID, action BunaAction, proposal *BunaProposal) (*models.Message, *BunaInvite, error) {
+	if _, err := activeMatchFor(matchID, actorID); err != nil {
+		return nil, nil, err
+	}
+
+	var message models.Message
+	var invite *BunaInvite
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND match_id = ? AND message_type = ?", messageID, matchID, models.MessageTypeBunaInvite).
+			First(&message).Error; err != nil {
+			return ErrBunaNotFound
+		}
+
+		var err error
+		if invite, err = BunaFromMetadata(message.Metadata); err != nil {
+			return ErrBunaNotFound
+		}
+
+		now := time.Now()
+		open := invite.Status == BunaStatusProposed || invite.Status == BunaStatusRescheduled
+		if !invite.ScheduledAt.After(now) {
+			return fmt.Errorf("%w: the buna time has passed", ErrBunaState)
+		}
+
+		switch action {
+		case BunaActionAccept, BunaActionDecline:
+			if !open {
+				return fmt.Errorf("%w: invite is %s", ErrBunaState, invite.Status)
+			}
+			if invite.ProposedBy == actorID {
+				return fmt.Errorf("%w: waiting for the other person to answer", ErrBunaState)
+			}
+			if action == BunaActionAccept {
+				invite.record(BunaStatusAccepted, actorID, now)
+			} else {
+				invite.record(BunaStatusDeclined, actorID, now)
+			}
+
+		case BunaActionReschedule:
+			if !open && invite.Status != BunaStatusAccepted {
+				return fmt.Errorf("%w: invite is %s", ErrBunaState, invite.Status)
+			}
+			if proposal == nil {
+				return invalidMessage("scheduled_at is required")
+			}
+			next := *proposal
+			// Keep the place unless a new one is given
+			if strings.TrimSpace(next.CafeName) == "" {
+				next.CafeName = invite.CafeName
+				next.CafeAddress = invite.CafeAddress
+				next.Latitude = invite.Latitude
+				next.Longitude = invite.Longitude
+			}
+			if next.Note == "" {
+				next.Note = invite.Note
+			}
+			if err := validateBunaProposal(&next); err != nil {
+				return err
+			}
+			if err := screenBunaText(message, actorID, proposal.CafeName, proposal.CafeAddress, proposal.Note); err != nil {
+				return err
+			}
+			invite.BunaProposal = next
+			invite.ProposedBy = actorID
+			invite.RescheduleCount++
+			invite.ReminderSentAt = nil
+			invite.record(BunaStatusRescheduled, actorID, now)
+
+		case BunaActionCancel:
+			if !open && invite.Status != BunaStatusAccepted {
+				return fmt.Errorf("%w: invite is %s", ErrBunaState, invite.Status)
+			}
+			invite.record(BunaStatusCancelled, actorID, now)
+
+		default:
+			return invalidMessage("action must be accept, decline, reschedule or cancel")
+		}
+
+		message.Metadata = invite.metadata()
+		if err := tx.Model(&message).Update("metadata", message.Metadata).Error; err != nil {
+			return err
+		}
+		return touchMessage(tx, &message)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	PublishMessageEvent("buna_update", message, message.Metadata)
+
+	if NotificationSvc != nil {
+		go NotificationSvc.NotifyBunaUpdate(message, *invite, actorID)
+	}
+
+	return &message, invite, nil
+}
+
+// SubmitBunaFeedback stores a participant's "how was it?" answer for a buna that took place.
+// If they did not feel safe (or asked to report), a report against the other user is filed.
+// Feedback goes to the buna_feedback table, never the invite, so the other user cannot see it.
+func SubmitBunaFeedback(userID, matchID, messageID uuid.UUID, feedback models.BunaFeedback, reportReason models.ReportReason) (*models.BunaFeedback, error) {
+	if feedback.Rating < 0 || feedback.Rating > 5 {
+		return nil, invalidMessage("rating must be between 1 and 5")
+	}
+	feedback.Comment = strings.TrimSpace(feedback.Comment)
+	if utf8.RuneCountInString(feedback.Comment) > 1000 {
+		return nil, invalidMessage("comment is too long")
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Feedback is allowed after an unmatch or block: that is when it matters most
+		var message models.Message
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND match_id = ? AND message_type = ? AND (sender_id = ? OR receiver_id = ?)",
+				messageID, matchID, models.MessageTypeBunaInvite, userID, userID).
+			First(&message).Error; err != nil {
+			return ErrBunaNotFound
+		}
+
+		invite, err := BunaFromMetadata(message.Metadata)
+		if err != nil {
+			return ErrBunaNotFound
+		}
+		if invite.Status != BunaStatusAccepted {
+			return fmt.Errorf("%w: the buna was not confirmed", ErrBunaState)
+		}
+		if time.Now().Before(invite.ScheduledAt) {
+			return fmt.Errorf("%w: the buna has not happened yet", ErrBunaState)
+		}
+		if time.Since(invite.ScheduledAt) > bunaFeedbackWindow {
+			return fmt.Errorf("%w: feedback is closed", ErrBunaState)
+		}
+		var sent int64
+		if err := tx.Model(&models.BunaFeedback{}).Where("message_id = ? AND user_id = ?", message.ID, userID).
+			Count(&sent).Error; err != nil {
+			return err
+		}
+		if sent > 0 {
+			return fmt.Errorf("%w: feedback already sent", ErrBunaState)
+		}
+
+		otherID := message.SenderID
+		if otherID == userID {
+			otherID = message.ReceiverID
+		}
+
+		// A comment filed with a report goes to moderators, so only the other is screened
+		reporting := (feedback.FeltSafe != nil && !*feedback.FeltSafe) || reportReason != ""
+		if !reporting {
+			if err := screenBunaText(message, userID, feedback.Comment); err != nil {
+				return err
+			}
+		}
+
+		if reporting {
+			if reportReason == "" {
+				reportReason = models.ReportReasonHarassment
+			}
+			report := models.Report{
+				ReporterID:     userID,
+				ReportedUserID: otherID,
+				Reason:         reportReason,
+				Description: fmt.Sprintf("Buna date on %s at %s. %s",
+					invite.ScheduledAt.In(bunaLocation).Format("Jan 2, 15:04"), invite.CafeName, feedback.Comment),
+			}
+			if err := tx.Create(&report).Error; err != nil {
+				return err
+			}
+			feedback.ReportID = &report.ID
+		}
+
+		feedback.MessageID = message.ID
+		feedback.UserID = userID
+		feedback.MatchID = message.MatchID
+		return tx.Create(&feedback).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// screenBunaText screens text a participant adds to an invite after it was sent. The flag is
+// recorded outside the caller's transaction so a rejected change still leaves a record.
+func screenBunaText(message models.Message, actorID uuid.UUID, texts ...string) error {
+	receiverID := message.ReceiverID
+	if actorID == message.ReceiverID {
+		receiverID = message.SenderID
+	}
+	in := ScreeningInput{
+		MatchID:     message.MatchID,
+		SenderID:    actorID,
+		ReceiverID:  receiverID,
+		MessageType: models.MessageTypeBunaInvite,
+		Fields:      texts,
+	}
+	screening := ScreenMessageUpdate(in)
+	if err := recordFlaggedMessage(database.DB, in, &message.ID, screening); err != nil {
+		return err
+	}
+	if screening.Action == models.ScreeningActionBlock {
+		return ErrMessageScreened
+	}
+	return nil
+}
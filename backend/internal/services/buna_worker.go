@@ -0,0 +1,152 @@
+package services
+
+import (
+	"log"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// bunaLockKey makes sure only one API instance runs the buna job at a time
+const bunaLockKey = "jobs:buna:lock"
+
+// StartBunaWorker periodically sends reminders before accepted bunas and "how was it?"
+// prompts after them. Blocks; run it in a goroutine.
+func StartBunaWorker() {
+	interval := time.Duration(config.Cfg.BunaCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	log.Printf("✅ Buna worker started (every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runBunaJob()
+		<-ticker.C
+	}
+}
+
+func runBunaJob() {
+	// Skip this run if another instance holds the lock
+	release, ok := acquireJobLock(bunaLockKey)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if n := sendBunaReminders(); n > 0 {
+		log.Printf("☕ Sent %d buna reminders", n)
+	}
+	if n := sendBunaFeedbackPrompts(); n > 0 {
+		log.Printf("☕ Sent %d buna feedback prompts", n)
+	}
+}
+
+// sendBunaReminders notifies both users once when an accepted buna in an active match is close
+func sendBunaReminders() int {
+	if config.Cfg.BunaReminderMinutes <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	reminderWindow := now.Add(time.Duration(config.Cfg.BunaReminderMinutes) * time.Minute)
+
+	var messages []models.Message
+	if err := database.DB.Model(&models.Message{}).
+		Select("messages.*").
+		Joins("JOIN matches ON matches.id = messages.match_id").
+		Where("messages.message_type = ? AND messages.metadata->>'status' = ? AND messages.metadata->>'reminder_sent_at' IS NULL",
+			models.MessageTypeBunaInvite, BunaStatusAccepted).
+		Where("(messages.metadata->>'scheduled_at')::timestamptz > ? AND (messages.metadata->>'scheduled_at')::timestamptz <= ?",
+			now, reminderWindow).
+		Where("matches.status = ?", models.MatchStatusActive).
+		Limit(500).
+		Find(&messages).Error; err != nil {
+		log.Printf("❌ Failed to load bunas for reminders: %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, message := range messages {
+		invite, ok := claimBunaFlag(message, "reminder_sent_at")
+		if !ok {
+			continue
+		}
+
+		if NotificationSvc != nil {
+			if err := NotificationSvc.NotifyBunaReminder(message, *invite); err != nil {
+				log.Printf("⚠️ Failed to send buna reminder for %s: %v", message.ID, err)
+			}
+		}
+		sent++
+	}
+	return sent
+}
+
+// sendBunaFeedbackPrompts asks both users how an accepted buna went, once, a few hours after
+// it. The match may have ended since: that is when the answer matters most.
+func sendBunaFeedbackPrompts() int {
+	now := time.Now()
+	due := now.Add(-time.Duration(config.Cfg.BunaFeedbackDelayHours) * time.Hour)
+
+	var messages []models.Message
+	if err := database.DB.
+		Where("message_type = ? AND metadata->>'status' = ? AND metadata->>'feedback_prompt_sent_at' IS NULL",
+			models.MessageTypeBunaInvite, BunaStatusAccepted).
+		Where("(metadata->>'scheduled_at')::timestamptz <= ? AND (metadata->>'scheduled_at')::timestamptz > ?",
+			due, now.Add(-bunaFeedbackWindow)).
+		Limit(500).
+		Find(&messages).Error; err != nil {
+		log.Printf("❌ Failed to load bunas for feedback prompts: %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, message := range messages {
+		invite, ok := claimBunaFlag(message, "feedback_prompt_sent_at")
+		if !ok {
+			continue
+		}
+
+		PublishMessageEvent("buna_feedback", message, invite)
+		if NotificationSvc != nil {
+			if err := NotificationSvc.NotifyBunaFeedback(message, *invite); err != nil {
+				log.Printf("⚠️ Failed to send buna feedback prompt for %s: %v", message.ID, err)
+			}
+		}
+		sent++
+	}
+	return sent
+}
+
+// claimBunaFlag stamps a one-time flag on an accepted invite so no other run sends it again.
+// The claim fails if the invite changed since it was loaded (e.g. it was rescheduled).
+func claimBunaFlag(message models.Message, flag string) (*BunaInvite, bool) {
+	scheduledAt, _ := message.Metadata["scheduled_at"].(string)
+	now := time.Now().UTC()
+
+	result := database.DB.Model(&models.Message{}).
+		Where("id = ? AND metadata->>'status' = ? AND metadata->>'scheduled_at' = ? AND metadata->>? IS NULL",
+			message.ID, BunaStatusAccepted, scheduledAt, flag).
+		Update("metadata", gorm.Expr("jsonb_set(metadata, ?::text[], to_jsonb(?::text))",
+			"{"+flag+"}", now.Format(time.RFC3339Nano)))
+	if result.Error != nil {
+		log.Printf("❌ Failed to claim buna %s for %s: %v", flag, message.ID, result.Error)
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		return nil, false
+	}
+
+	invite, err := BunaFromMetadata(message.Metadata)
+	if err != nil {
+		return nil, false
+	}
+	return invite, true
+}
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSearchQueryLength  = 100  // Runes
+	maxTranscriptMessages = 5000 // Most recent messages kept in an export
+	transcriptContentType = "application/json"
+)
+
+// ErrTranscriptNotFound is returned when a report has no transcript snapshot
+var ErrTranscriptNotFound = errors.New("transcript not found")
+
+// MessageSearchResult is a message matching a search, with the match it belongs to
+type MessageSearchResult struct {
+	ID          uuid.UUID          `json:"id"`
+	MatchID     uuid.UUID          `json:"match_id"`
+	SenderID    uuid.UUID          `json:"sender_id"`
+	MessageType models.MessageType `json:"message_type"`
+	Content     string             `json:"content"`
+	Seq         int64              `json:"seq"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// MessageSearch is one page of search results, newest first
+type MessageSearch struct {
+	Query    string
+	MatchID  *uuid.UUID // Limit the search to one conversation
+	Limit    int
+	BeforeAt *time.Time // Keyset cursor: results strictly older than (BeforeAt, BeforeID)
+	BeforeID uuid.UUID
+}
+
+// hasEthiopic reports whether s contains Ethiopic (Ge'ez) script
+func hasEthiopic(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Ethiopic, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// likeEscaper escapes LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchMessages runs a full-text search over the text of the user's active conversations
+// (current epoch only), skipping messages they deleted, tombstones and messages held from
+// them. Queries use web search syntax ("quoted phrases", -exclusions, or). Amharic words
+// carry affixes the tokenizer keeps attached, so Ethiopic queries also match substrings.
+func SearchMessages(userID uuid.UUID, search MessageSearch) ([]MessageSearchResult, error) {
+	query := strings.TrimSpace(search.Query)
+	if query == "" {
+		return nil, invalidMessage("q is required")
+	}
+	if !utf8.ValidString(query) || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return nil, invalidMessage(fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength))
+	}
+
+	// Ethiopic punctuation separates words, as in the content_tsv column
+	normalized := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("፡።፣፤፥፦፧፨", r) {
+			return ' '
+		}
+		return r
+	}, query)
+
+	db := database.DB.Table("messages m").
+		Select("m.id, m.match_id, m.sender_id, m.message_type, m.content, m.seq, m.created_at").
+		Joins("JOIN matches ma ON ma.id = m.match_id AND ma.epoch = m.epoch").
+		Where("(ma.user1_id = ? OR ma.user2_id = ?) AND ma.status = ?", userID, userID, models.MatchStatusActive).
+		Where("m.deleted_for_everyone_at IS NULL").
+		Where("m.held = false OR m.sender_id = ?", userID).
+		Where("NOT EXISTS (SELECT 1 FROM message_deletions md WHERE md.message_id = m.id AND md.user_id = ?)", userID)
+
+	if hasEthiopic(normalized) {
+		db = db.Where("m.content_tsv @@ websearch_to_tsquery('simple', ?) OR m.content ILIKE ?",
+			normalized, "%"+likeEscaper.Replace(strings.TrimSpace(normalized))+"%")
+	} else {
+		db = db.Where("m.content_tsv @@ websearch_to_tsquery('simple', ?)", normalized)
+	}
+
+	if search.MatchID != nil {
+		db = db.Where("m.match_id = ?", *search.MatchID)
+	}
+	if search.BeforeAt != nil {
+		db = db.Where("(m.created_at, m.id) < (?, ?)", *search.BeforeAt, search.BeforeID)
+	}
+
+	results := make([]MessageSearchResult, 0)
+	if err := db.Order("m.created_at DESC, m.id DESC").Limit(search.Limit).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// TranscriptParticipant is a user in a transcript
+type TranscriptParticipant struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// TranscriptMessage is one message in a transcript. MediaKey is only kept in report
+// snapshots; exports carry a signed MediaURL instead.
+type TranscriptMessage struct {
+	ID             uuid.UUID          `json:"id"`
+	Seq            int64              `json:"seq"`
+	SenderID       uuid.UUID          `json:"sender_id"`
+	SenderName     string             `json:"sender_name"`
+	MessageType    models.MessageType `json:"message_type"`
+	Content        string             `json:"content,omitempty"`
+	MediaKey       string             `json:"media_key,omitempty"`
+	MediaURL       string             `json:"media_url,omitempty"`
+	Metadata       models.JSONMap     `json:"metadata,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	EditedAt       *time.Time         `json:"edited_at,omitempty"`
+	Deleted        bool               `json:"deleted,omitempty"`          // Deleted for everyone
+	HiddenByViewer bool               `json:"hidden_by_viewer,omitempty"` // Deleted for the viewer (report snapshots only)
+}
+
+// ChatTranscript is a conversation as one participant saw it
+type ChatTranscript struct {
+	MatchID           uuid.UUID               `json:"match_id"`
+	Epoch             int                     `json:"epoch"`
+	MatchStatus       models.MatchStatus      `json:"match_status"`
+	ExportedBy        uuid.UUID               `json:"exported_by"`
+	ExportedAt        time.Time               `json:"exported_at"`
+	Participants      []TranscriptParticipant `json:"participants"`
+	Messages          []TranscriptMessage     `json:"messages"`
+	Truncated         bool                    `json:"truncated"` // Only the most recent maxTranscriptMessages are included
+	MediaURLsExpireAt *time.Time              `json:"media_urls_expire_at,omitempty"`
+}
+
+// BuildChatTranscript collects the current epoch of a conversation as the viewer sees it.
+// Ended matches are included, so a user can still export a chat after a block. Messages
+// the viewer deleted for themselves are left out unless includeHidden is set, which report
+// snapshots use so that evidence the reporter cleared from their screen is kept.
+func BuildChatTranscript(viewerID, matchID uuid.UUID, includeHidden bool) (*ChatTranscript, error) {
+	var match models.Match
+	if err := database.DB.Where("id = ? AND (user1_id = ? OR user2_id = ?)", matchID, viewerID, viewerID).
+		First(&match).Error; err != nil {
+		return nil, ErrMatchNotFound
+	}
+
+	var users []models.User
+	if err := database.DB.Select("id", "name").Where("id IN ?", []uuid.UUID{match.User1ID, match.User2ID}).
+		Find(&users).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uuid.UUID]string, len(users))
+	participants := make([]TranscriptParticipant, 0, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+		participants = append(participants, TranscriptParticipant{ID: u.ID, Name: u.Name})
+	}
+
+	var messages []models.Message
+	if err := database.DB.Where("match_id = ? AND epoch = ?", match.ID, match.Epoch).
+		Where("held = false OR sender_id = ?", viewerID).
+		Order("seq DESC").
+		Limit(maxTranscriptMessages + 1).
+		Find(&messages).Error; err != nil {
+		return nil, err
+	}
+	truncated := len(messages) > maxTranscriptMessages
+	if truncated {
+		messages = messages[:maxTranscriptMessages]
+	}
+
+	hidden := make(map[uuid.UUID]bool)
+	if len(messages) > 0 {
+		ids := make([]uuid.UUID, len(messages))
+		for i, m := range messages {
+			ids[i] = m.ID
+		}
+		var hiddenIDs []uuid.UUID
+		if err := database.DB.Model(&models.MessageDeletion{}).
+			Where("user_id = ? AND message_id IN ?", viewerID, ids).
+			Pluck("message_id", &hiddenIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range hiddenIDs {
+			hidden[id] = true
+		}
+	}
+
+	transcript := &ChatTranscript{
+		MatchID:      match.ID,
+		Epoch:        match.Epoch,
+		MatchStatus:  match.Status,
+		ExportedBy:   viewerID,
+		ExportedAt:   time.Now(),
+		Participants: participants,
+		Messages:     make([]TranscriptMessage, 0, len(messages)),
+		Truncated:    truncated,
+	}
+	// Oldest first
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		if hidden[m.ID] && !includeHidden {
+			continue
+		}
+		tm := TranscriptMessage{
+			ID:             m.ID,
+			Seq:            m.Seq,
+			SenderID:       m.SenderID,
+			SenderName:     names[m.SenderID],
+			MessageType:    m.MessageType,
+			Content:        m.Content,
+			Metadata:       m.Metadata,
+			CreatedAt:      m.CreatedAt,
+			EditedAt:       m.EditedAt,
+			Deleted:        m.DeletedForEveryoneAt != nil,
+			HiddenByViewer: hidden[m.ID],
+		}
+		if strings.HasPrefix(m.MediaURL, chatMediaKeyPrefix) {
+			tm.MediaKey = m.MediaURL
+		} else {
+			tm.MediaURL = m.MediaURL
+		}
+		if len(tm.Metadata) == 0 {
+			tm.Metadata = nil
+		}
+		transcript.Messages = append(transcript.Messages, tm)
+	}
+	return transcript, nil
+}
+
+// SignMedia replaces chat media keys with download URLs (valid for chatMediaDownloadURLTTL)
+func (t *ChatTranscript) SignMedia() {
+	signed := false
+	for i := range t.Messages {
+		if t.Messages[i].MediaKey == "" {
+			continue
+		}
+		t.Messages[i].MediaURL = ChatMediaURL(t.Messages[i].MediaKey)
+		t.Messages[i].MediaKey = ""
+		signed = true
+	}
+	if signed {
+		expiresAt := time.Now().Add(chatMediaDownloadURLTTL)
+		t.MediaURLsExpireAt = &expiresAt
+	}
+}
+
+var transcriptTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"time": func(t time.Time) string { return t.In(bunaLocation).Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Lomi chat transcript</title>
+<style>
+body { font-family: "Noto Sans Ethiopic", "Nyala", sans-serif; max-width: 720px; margin: 2em auto; color: #222; }
+.meta { color: #666; font-size: 0.9em; }
+.msg { margin: 0.6em 0; padding: 0.5em 0.8em; border-left: 3px solid #e53935; }
+.msg.deleted { border-color: #aaa; color: #888; font-style: italic; }
+.sender { font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Chat transcript</h1>
+<p class="meta">
+Participants: {{range $i, $p := .Participants}}{{if $i}}, {{end}}{{$p.Name}}{{end}}<br>
+Exported {{time .ExportedAt}} (EAT) · match {{.MatchID}} · {{.MatchStatus}}
+{{if .Truncated}}<br>Only the most recent {{len .Messages}} messages are included.{{end}}
+{{with .MediaURLsExpireAt}}<br>Media links expire {{time .}} (EAT).{{end}}
+</p>
+{{range .Messages}}
+<div class="msg{{if .Deleted}} deleted{{end}}">
+<div class="meta"><span class="sender">{{.SenderName}}</span> · {{time .CreatedAt}}{{if .EditedAt}} · edited{{end}}{{if .HiddenByViewer}} · hidden by viewer{{end}}</div>
+{{if .Deleted}}This message was deleted.{{else}}
+{{if .Content}}<div>{{.Content}}</div>{{end}}
+{{if .MediaURL}}<div><a href="{{.MediaURL}}">{{.MessageType}}</a></div>{{else if ne .MessageType "text"}}<div class="meta">[{{.MessageType}}]</div>{{end}}
+{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML renders the transcript as a standalone HTML page
+func (t *ChatTranscript) RenderHTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := transcriptTemplate.Execute(&buf, t); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportChat builds a transcript of a conversation with signed media links
+func ExportChat(userID, matchID uuid.UUID) (*ChatTranscript, error) {
+	transcript, err := BuildChatTranscript(userID, matchID, false)
+	if err != nil {
+		return nil, err
+	}
+	transcript.SignMedia()
+	return transcript, nil
+}
+
+// AttachReportTranscript snapshots the reporter's conversation with the reported user into
+// the chat bucket, so the evidence survives later edits and deletions. Media keys are kept
+// unsigned and signed again when an admin opens the transcript.
+func AttachReportTranscript(report *models.Report, matchID uuid.UUID) error {
+	transcript, err := BuildChatTranscript(report.ReporterID, matchID, true)
+	if err != nil {
+		return err
+	}
+	inMatch := false
+	for _, p := range transcript.Participants {
+		if p.ID == report.ReportedUserID {
+			inMatch = true
+		}
+	}
+	if !inMatch {
+		return ErrMatchNotFound
+	}
+
+	body, err := json.Marshal(transcript)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("reports/%s/transcript.json", report.ID)
+	if err := database.PutObject(context.Background(), config.Cfg.S3BucketChat, key, transcriptContentType, body); err != nil {
+		return err
+	}
+
+	report.MatchID = &matchID
+	report.TranscriptKey = key
+	return database.DB.Model(report).Updates(map[string]interface{}{
+		"match_id":       matchID,
+		"transcript_key": key,
+	}).Error
+}
+
+// ReportTranscript loads the transcript snapshot of a report, with media signed for review
+func ReportTranscript(reportID uuid.UUID) (*ChatTranscript, error) {
+	var report models.Report
+	if err := database.DB.Select("id", "transcript_key").First(&report, "id = ?", reportID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTranscriptNotFound
+		}
+		return nil, err
+	}
+	if report.TranscriptKey == "" {
+		return nil, ErrTranscriptNotFound
+	}
+
+	body, err := database.GetObject(context.Background(), config.Cfg.S3BucketChat, report.TranscriptKey)
+	if err != nil {
+		return nil, err
+	}
+	var transcript ChatTranscript
+	if err := json.Unmarshal(body, &transcript); err != nil {
+		return nil, err
+	}
+	transcript.SignMedia()
+	return &transcript, nil
+}
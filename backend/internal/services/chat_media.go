@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+)
+
+const (
+	chatMediaKeyPrefix      = "chats/"
+	chatMediaUploadURLTTL   = 15 * time.Minute
+	chatMediaDownloadURLTTL = time.Hour
+)
+
+// chatMediaRule bounds what may be uploaded for a media message type
+type chatMediaRule struct {
+	MaxBytes          int64
+	MaxDuration       int               // Seconds; 0 = no duration (photos)
+	MaxBytesPerSecond int64             // Rejects files far larger than their declared duration allows
+	ContentTypes      map[string]string // Content-Type -> file extension
+}
+
+var chatMediaRules = map[models.MessageType]chatMediaRule{
+	models.MessageTypePhoto: {
+		MaxBytes: 10 << 20,
+		ContentTypes: map[string]string{
+			"image/jpeg": ".jpg",
+			"image/png":  ".png",
+			"image/webp": ".webp",
+		},
+	},
+	models.MessageTypeVideo: {
+		MaxBytes:          50 << 20,
+		MaxDuration:       60,
+		MaxBytesPerSecond: 2 << 20,
+		ContentTypes: map[string]string{
+			"video/mp4":       ".mp4",
+			"video/quicktime": ".mov",
+			"video/webm":      ".webm",
+		},
+	},
+	models.MessageTypeVoice: {
+		MaxBytes:          5 << 20,
+		MaxDuration:       120,
+		MaxBytesPerSecond: 64 << 10,
+		ContentTypes: map[string]string{
+			"audio/ogg":  ".ogg",
+			"audio/webm": ".webm",
+			"audio/mp4":  ".m4a",
+			"audio/aac":  ".aac",
+			"audio/mpeg": ".mp3",
+		},
+	},
+}
+
+// IsChatMediaType reports whether a message type carries an uploaded file
+func IsChatMediaType(t models.MessageType) bool {
+	_, ok := chatMediaRules[t]
+	return ok
+}
+
+// ChatMediaUploadRequest describes the file a client is about to upload
+type ChatMediaUploadRequest struct {
+	MediaType       models.MessageType
+	ContentType     string
+	SizeBytes       int64
+	DurationSeconds int
+}
+
+// ChatMediaUpload is a presigned upload for one chat file. The client PUTs the file with
+// the given headers, then sends a message with MediaID.
+type ChatMediaUpload struct {
+	MediaID   uuid.UUID         `json:"media_id"`
+	UploadURL string            `json:"upload_url"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresIn int               `json:"expires_in"`
+}
+
+// CreateChatMediaUpload validates the declared file and hands out an upload URL into the
+// private chat bucket. Only participants of an active match may upload.
+func CreateChatMediaUpload(uploaderID, matchID uuid.UUID, req ChatMediaUploadRequest) (*ChatMediaUpload, error) {
+	if database.S3Client == nil {
+		return nil, fmt.Errorf("S3Client is not initialized")
+	}
+
+	if _, err := activeMatchFor(matchID, uploaderID); err != nil {
+		return nil, err
+	}
+
+	rule, ok := chatMediaRules[req.MediaType]
+	if !ok {
+		return nil, invalidMessage("media_type must be photo, video or voice")
+	}
+	ext, ok := rule.ContentTypes[req.ContentType]
+	if !ok {
+		return nil, invalidMessage("unsupported content type for " + string(req.MediaType))
+	}
+	if err := checkChatMediaSize(rule, req.SizeBytes, req.DurationSeconds); err != nil {
+		return nil, err
+	}
+
+	// chats/{match_id}/{uploader_id}/{uuid}.{ext}
+	media := models.ChatMedia{
+		ID:              uuid.New(),
+		MatchID:         matchID,
+		UploaderID:      uploaderID,
+		MediaType:       req.MediaType,
+		ContentType:     req.ContentType,
+		SizeBytes:       req.SizeBytes,
+		DurationSeconds: req.DurationSeconds,
+		Status:          models.ChatMediaStatusPending,
+	}
+	media.ObjectKey = fmt.Sprintf("%s%s/%s/%s%s", chatMediaKeyPrefix, matchID, uploaderID, media.ID, ext)
+
+	uploadURL, err := database.GeneratePresignedPutURL(context.Background(), config.Cfg.S3BucketChat,
+		media.ObjectKey, media.ContentType, media.SizeBytes, chatMediaUploadURLTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := database.DB.Create(&media).Error; err != nil {
+		return nil, err
+	}
+
+	return &ChatMediaUpload{
+		MediaID:   media.ID,
+		UploadURL: uploadURL,
+		Method:    "PUT",
+		Headers: map[string]string{
+			"Content-Type":   media.ContentType,
+			"Content-Length": fmt.Sprint(media.SizeBytes),
+		},
+		ExpiresIn: int(chatMediaUploadURLTTL.Seconds()),
+	}, nil
+}
+
+func checkChatMediaSize(rule chatMediaRule, sizeBytes int64, durationSeconds int) error {
+	if sizeBytes <= 0 {
+		return invalidMessage("size_bytes is required")
+	}
+	if sizeBytes > rule.MaxBytes {
+		return invalidMessage(fmt.Sprintf("file is too large (max %d MB)", rule.MaxBytes>>20))
+	}
+	if rule.MaxDuration == 0 {
+		return nil
+	}
+	if durationSeconds <= 0 {
+		return invalidMessage("duration_seconds is required")
+	}
+	if durationSeconds > rule.MaxDuration {
+		return invalidMessage(fmt.Sprintf("too long (max %d seconds)", rule.MaxDuration))
+	}
+	if sizeBytes > int64(durationSeconds+1)*rule.MaxBytesPerSecond {
+		return invalidMessage("file size does not match its duration")
+	}
+	return nil
+}
+
+// attachableChatMedia loads an upload the sender may attach to a message of the given type
+// in the match, and checks the stored object against what was declared
+func attachableChatMedia(mediaID, senderID, matchID uuid.UUID, mediaType models.MessageType) (*models.ChatMedia, error) {
+	var media models.ChatMedia
+	if err := database.DB.Where("id = ? AND uploader_id = ? AND match_id = ?", mediaID, senderID, matchID).
+		First(&media).Error; err != nil {
+		return nil, invalidMessage("media not found")
+	}
+	if media.Status != models.ChatMediaStatusPending {
+		return nil, invalidMessage("media was already sent")
+	}
+	if media.MediaType != mediaType {
+		return nil, invalidMessage("media was uploaded as " + string(media.MediaType))
+	}
+
+	head, err := database.HeadObject(context.Background(), config.Cfg.S3BucketChat, media.ObjectKey)
+	if err != nil {
+		return nil, invalidMessage("media has not been uploaded")
+	}
+	if aws.ToInt64(head.ContentLength) != media.SizeBytes {
+		return nil, invalidMessage("uploaded file size does not match")
+	}
+	if ct := aws.ToString(head.ContentType); ct != "" && ct != media.ContentType {
+		return nil, invalidMessage("uploaded file type does not match")
+	}
+
+	return &media, nil
+}
+
+// ChatMediaURL turns a stored chat media key into a short-lived download URL.
+// Values that are not chat keys (legacy absolute URLs, stickers) are returned unchanged.
+func ChatMediaURL(mediaURL string) string {
+	if !strings.HasPrefix(mediaURL, chatMediaKeyPrefix) {
+		return mediaURL
+	}
+	url, err := database.GeneratePresignedDownloadURL(context.Background(), config.Cfg.S3BucketChat, mediaURL, chatMediaDownloadURLTTL)
+	if err != nil {
+		log.Printf("⚠️ Failed to sign chat media %s: %v", mediaURL, err)
+		return ""
+	}
+	return url
+}
+
+// PresignChatMessages replaces stored chat media keys with download URLs, for responses.
+// Callers must only pass messages of matches the viewer participates in.
+func PresignChatMessages(messages []models.Message) {
+	for i := range messages {
+		messages[i].MediaURL = ChatMediaURL(messages[i].MediaURL)
+	}
+}
+
+// ChatMediaDownloadURL returns a fresh download URL for a file of a match the user is in
+func ChatMediaDownloadURL(userID, matchID, mediaID uuid.UUID) (string, error) {
+	if _, err := activeMatchFor(matchID, userID); err != nil {
+		return "", err
+	}
+
+	var media models.ChatMedia
+	if err := database.DB.Where("id = ? AND match_id = ? AND status = ?", mediaID, matchID, models.ChatMediaStatusAttached).
+		First(&media).Error; err != nil {
+		return "", ErrMatchNotFound
+	}
+
+	url := ChatMediaURL(media.ObjectKey)
+	if url == "" {
+		return "", fmt.Errorf("failed to sign chat media")
+	}
+	return url, nil
+}
+
+// activeMatchFor loads an active match the user participates in
+func activeMatchFor(matchID, userID uuid.UUID) (*models.Match, error) {
+	var match models.Match
+	if err := database.DB.Where("id = ? AND (user1_id = ? OR user2_id = ?) AND status = ?",
+		matchID, userID, userID, models.MatchStatusActive).
+		First(&match).Error; err != nil {
+		return nil, ErrMatchNotFound
+	}
+	return &match, nil
+}
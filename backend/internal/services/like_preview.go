@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	_ "image/gif" // Decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"log"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Unrevealed likers are shown as a photo shrunk to a few pixels: clients scale it up behind
+// a blur, and there is nothing sharper on the device to un-blur. Previews are made once per
+// photo and kept in media.blur_preview.
+const (
+	likePreviewWidth      = 12
+	likePreviewMaxSamples = 256 // Source pixels sampled per axis
+	likePreviewWorkers    = 8
+)
+
+// LikePreviewPhoto is the photo a preview is made from
+type LikePreviewPhoto struct {
+	MediaID      uuid.UUID
+	Key          string // Object key in the photos bucket
+	ThumbnailKey string // Preferred when present: smaller to fetch and decode
+	Preview      string // Stored preview, if already made
+}
+
+// LikePreviews returns a preview data URI per photo (empty when one cannot be made),
+// making missing ones concurrently
+func LikePreviews(photos []LikePreviewPhoto) []string {
+	previews := make([]string, len(photos))
+	sem := make(chan struct{}, likePreviewWorkers)
+	var wg sync.WaitGroup
+	for i, photo := range photos {
+		if photo.Preview != "" || photo.MediaID == uuid.Nil {
+			previews[i] = photo.Preview
+			continue
+		}
+		wg.Add(1)
+		go func(i int, photo LikePreviewPhoto) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			previews[i] = makeLikePreview(photo)
+		}(i, photo)
+	}
+	wg.Wait()
+	return previews
+}
+
+func makeLikePreview(photo LikePreviewPhoto) string {
+	key := photo.ThumbnailKey
+	if key == "" {
+		key = photo.Key
+	}
+	data, err := database.GetObject(context.Background(), config.Cfg.S3BucketPhotos, key)
+	if err != nil {
+		log.Printf("⚠️ Failed to fetch photo %s for preview: %v", photo.MediaID, err)
+		return ""
+	}
+	preview, err := blurPreview(data)
+	if err != nil {
+		log.Printf("⚠️ Failed to make preview of photo %s: %v", photo.MediaID, err)
+		return ""
+	}
+
+	if err := database.DB.Model(&models.Media{}).Where("id = ?", photo.MediaID).
+		Update("blur_preview", preview).Error; err != nil {
+		log.Printf("⚠️ Failed to store preview of photo %s: %v", photo.MediaID, err)
+	}
+	return preview
+}
+
+// blurPreview box-averages an image down to likePreviewWidth pixels wide and encodes it
+// as a JPEG data URI
+func blurPreview(data []byte) (string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w == 0 || h == 0 {
+		return "", image.ErrFormat
+	}
+
+	tw := likePreviewWidth
+	th := (h*tw + w/2) / w
+	if th < 1 {
+		th = 1
+	}
+	if th > 3*tw {
+		th = 3 * tw
+	}
+
+	stepX, stepY := w/likePreviewMaxSamples+1, h/likePreviewMaxSamples+1
+	type sum struct{ r, g, b, n uint64 }
+	sums := make([]sum, tw*th)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += stepY {
+		ty := (y - bounds.Min.Y) * th / h
+		for x := bounds.Min.X; x < bounds.Max.X; x += stepX {
+			tx := (x - bounds.Min.X) * tw / w
+			r, g, b, _ := src.At(x, y).RGBA()
+			s := &sums[ty*tw+tx]
+			s.r += uint64(r >> 8)
+			s.g += uint64(g >> 8)
+			s.b += uint64(b >> 8)
+			s.n++
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for i, s := range sums {
+		if s.n == 0 {
+			continue
+		}
+		dst.SetRGBA(i%tw, i/tw, color.RGBA{R: uint8(s.r / s.n), G: uint8(s.g / s.n), B: uint8(s.b / s.n), A: 255})
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 70}); err != nil {
+		return "", err
+	}
+	return "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
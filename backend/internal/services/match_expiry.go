@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"log"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// matchExpiryLockKey makes sure only one API instance runs the expiry job at a time
+const matchExpiryLockKey = "jobs:match_expiry:lock"
+
+// MatchExpiryFrom returns when a match started at t expires if nobody writes, or nil if
+// expiry is disabled (MATCH_EXPIRY_HOURS <= 0)
+func MatchExpiryFrom(t time.Time) *time.Time {
+	if config.Cfg == nil || config.Cfg.MatchExpiryHours <= 0 {
+		return nil
+	}
+	expiresAt := t.Add(time.Duration(config.Cfg.MatchExpiryHours) * time.Hour)
+	return &expiresAt
+}
+
+// StartMatchExpiryWorker periodically expires unanswered matches and sends reminders.
+// Blocks; run it in a goroutine.
+func StartMatchExpiryWorker() {
+	interval := time.Duration(config.Cfg.MatchExpiryCheckInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+
+	log.Printf("✅ Match expiry worker started (every %s)", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		runMatchExpiryJob()
+		<-ticker.C
+	}
+}
+
+// jobLockTTL bounds how long a crashed instance can keep a job from running
+const jobLockTTL = time.Minute
+
+// releaseJobLockScript deletes a job lock only if it still holds this run's token, so a
+// run that outlived jobLockTTL cannot release a lock another instance has since taken
+var releaseJobLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// acquireJobLock takes a job lock for one run. It reports false if another instance holds
+// it (or Redis failed); otherwise call release when the run ends. Without Redis every
+// instance runs the job.
+func acquireJobLock(key string) (release func(), ok bool) {
+	if database.RedisClient == nil {
+		return func() {}, true
+	}
+
+	ctx := context.Background()
+	token := uuid.New().String()
+	acquired, err := database.RedisClient.SetNX(ctx, key, token, jobLockTTL).Result()
+	if err != nil || !acquired {
+		return nil, false
+	}
+	return func() {
+		if err := releaseJobLockScript.Run(ctx, database.RedisClient, []string{key}, token).Err(); err != nil {
+			log.Printf("⚠️ Failed to release job lock %s: %v", key, err)
+		}
+	}, true
+}
+
+func runMatchExpiryJob() {
+	// Skip this run if another instance holds the lock
+	release, ok := acquireJobLock(matchExpiryLockKey)
+	if !ok {
+		return
+	}
+	defer release()
+
+	if n := sendMatchExpiryReminders(); n > 0 {
+		log.Printf("⏰ Sent %d match expiry reminders", n)
+	}
+	if n := expireMatches(); n > 0 {
+		log.Printf("⌛ Expired %d unanswered matches", n)
+	}
+}
+
+// sendMatchExpiryReminders notifies both users once when an unanswered match is close to expiring
+func sendMatchExpiryReminders() int {
+	if config.Cfg.MatchReminderHours <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	reminderWindow := now.Add(time.Duration(config.Cfg.MatchReminderHours) * time.Hour)
+
+	var matches []models.Match
+	if err := database.DB.Where("status = ? AND first_message_at IS NULL AND expiry_reminder_sent = ? AND expires_at > ? AND expires_at <= ?",
+		models.MatchStatusActive, false, now, reminderWindow).
+		Limit(500).
+		Find(&matches).Error; err != nil {
+		log.Printf("❌ Failed to load matches for expiry reminders: %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, match := range matches {
+		// Claim the reminder so no other run sends it again
+		result := database.DB.Model(&models.Match{}).
+			Where("id = ? AND expiry_reminder_sent = ?", match.ID, false).
+			Update("expiry_reminder_sent", true)
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		if NotificationSvc != nil {
+			if err := NotificationSvc.NotifyMatchExpiring(match); err != nil {
+				log.Printf("⚠️ Failed to send match expiry reminder for %s: %v", match.ID, err)
+			}
+		}
+		sent++
+	}
+	return sent
+}
+
+// expireMatches ends active matches whose epoch passed its expiry without a first message
+func expireMatches() int {
+	var matches []models.Match
+	if err := database.DB.Where("status = ? AND first_message_at IS NULL AND expires_at <= ?",
+		models.MatchStatusActive, time.Now()).
+		Limit(500).
+		Find(&matches).Error; err != nil {
+		log.Printf("❌ Failed to load expired matches: %v", err)
+		return 0
+	}
+
+	expired := 0
+	for i := range matches {
+		match := matches[i]
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Re-check under the row lock: a message or extension may have just landed
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND status = ? AND first_message_at IS NULL AND expires_at <= ?",
+				match.ID, models.MatchStatusActive, time.Now()).First(&match).Error; err != nil {
+				return err
+			}
+			return EndMatch(tx, &match, models.MatchStatusExpired, nil)
+		})
+		if err == nil {
+			InvalidateMatchMembers(match.ID)
+			expired++
+		} else if err != gorm.ErrRecordNotFound {
+			log.Printf("❌ Failed to expire match %s: %v", match.ID, err)
+		}
+	}
+	return expired
+}
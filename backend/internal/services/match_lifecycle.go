@@ -0,0 +1,117 @@
+package services
+
+import (
+	"lomi-backend/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RematchCooldown is how long unmatched users are kept out of each other's discovery.
+// After it passes they can swipe on each other again; a mutual like starts a new epoch.
+const RematchCooldown = 30 * 24 * time.Hour
+
+// RecordMatchEvent appends an entry to the match history
+func RecordMatchEvent(tx *gorm.DB, match *models.Match, eventType models.MatchEventType, actorID *uuid.UUID) error {
+	event := models.MatchEvent{
+		MatchID:   match.ID,
+		ActorID:   actorID,
+		EventType: eventType,
+		Epoch:     match.Epoch,
+	}
+	return tx.Create(&event).Error
+}
+
+// EndMatch moves an active match to an end state (unmatched, expired or blocked).
+// The pair's swipes are removed so the two users can meet again later (discovery keeps
+// unmatched pairs apart for RematchCooldown; blocks are excluded separately).
+// Call InvalidateMatchMembers once tx commits: invalidating earlier lets a concurrent
+// reader cache the match as still active.
+func EndMatch(tx *gorm.DB, match *models.Match, status models.MatchStatus, actorID *uuid.UUID) error {
+	if err := endEpoch(tx, match, status, actorID); err != nil {
+		return err
+	}
+
+	if err := tx.Where("(swiper_id = ? AND swiped_id = ?) OR (swiper_id = ? AND swiped_id = ?)",
+		match.User1ID, match.User2ID, match.User2ID, match.User1ID).
+		Delete(&models.Swipe{}).Error; err != nil {
+		return err
+	}
+
+	eventType := models.MatchEventUnmatched
+	switch status {
+	case models.MatchStatusExpired:
+		eventType = models.MatchEventExpired
+	case models.MatchStatusBlocked:
+		eventType = models.MatchEventBlocked
+	}
+	return RecordMatchEvent(tx, match, eventType, actorID)
+}
+
+// RewindMatch ends a match whose completing like was rewound. Unlike EndMatch it keeps the
+// other user's swipe (the caller removes only its own) and starts no rematch cooldown, so
+// the rewinder can be shown the other user again and a new like matches them again.
+// As with EndMatch, call InvalidateMatchMembers once tx commits.
+func RewindMatch(tx *gorm.DB, match *models.Match, actorID uuid.UUID) error {
+	if err := endEpoch(tx, match, models.MatchStatusRewound, &actorID); err != nil {
+		return err
+	}
+	return RecordMatchEvent(tx, match, models.MatchEventRewound, &actorID)
+}
+
+// endEpoch marks an active match as ended with the given status
+func endEpoch(tx *gorm.DB, match *models.Match, status models.MatchStatus, actorID *uuid.UUID) error {
+	now := time.Now()
+	match.Status = status
+	match.IsActive = false
+	match.UnmatchedBy = actorID
+	match.UnmatchedAt = &now
+
+	return tx.Model(match).Updates(map[string]interface{}{
+		"status":       match.Status,
+		"is_active":    false,
+		"unmatched_by": match.UnmatchedBy,
+		"unmatched_at": match.UnmatchedAt,
+	}).Error
+}
+
+// Rematch reactivates an ended match as a new epoch. Messages from earlier epochs are hidden.
+// As with EndMatch, call InvalidateMatchMembers once tx commits.
+func Rematch(tx *gorm.DB, match *models.Match, actorID uuid.UUID) error {
+	match.Status = models.MatchStatusActive
+	match.IsActive = true
+	match.UnmatchedBy = nil
+	match.UnmatchedAt = nil
+	match.Epoch++
+	match.EpochStartedAt = time.Now()
+	match.InitiatedBy = actorID
+	match.ExpiresAt = MatchExpiryFrom(match.EpochStartedAt)
+	match.FirstMessageAt = nil
+	match.ExpiryReminderSent = false
+	match.ExtensionCount = 0
+
+	if err := tx.Model(match).Updates(map[string]interface{}{
+		"status":               match.Status,
+		"is_active":            true,
+		"unmatched_by":         nil,
+		"unmatched_at":         nil,
+		"epoch":                match.Epoch,
+		"epoch_started_at":     match.EpochStartedAt,
+		"initiated_by":         actorID,
+		"expires_at":           match.ExpiresAt,
+		"first_message_at":     nil,
+		"expiry_reminder_sent": false,
+		"extension_count":      0,
+	}).Error; err != nil {
+		return err
+	}
+
+	return RecordMatchEvent(tx, match, models.MatchEventRematched, &actorID)
+}
+
+// CanRematch reports whether an ended match may start a new epoch
+func CanRematch(match models.Match) bool {
+	return match.Status == models.MatchStatusUnmatched || match.Status == models.MatchStatusExpired ||
+		match.Status == models.MatchStatusRewound
+}
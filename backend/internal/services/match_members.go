@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Match membership is read on every typing and read-receipt frame, so it is cached in
+// memory (per node, briefly) and in Redis (shared). Ending or restarting a match
+// invalidates both; other nodes may keep a stale entry for up to matchMembersLocalTTL.
+const (
+	matchMembersPrefix     = "match:members:"
+	matchMembersLocalTTL   = time.Minute
+	matchMembersRedisTTL   = 10 * time.Minute
+	matchMembersSweepAfter = 10000 // Drop expired local entries once the cache grows past this
+)
+
+// MatchMembers is who is in a match and whether it is active
+type MatchMembers struct {
+	User1ID uuid.UUID `json:"user1_id"`
+	User2ID uuid.UUID `json:"user2_id"`
+	Active  bool      `json:"active"`
+}
+
+// Peer returns the other participant, or false if userID is not in the match
+func (m MatchMembers) Peer(userID uuid.UUID) (uuid.UUID, bool) {
+	switch userID {
+	case m.User1ID:
+		return m.User2ID, true
+	case m.User2ID:
+		return m.User1ID, true
+	}
+	return uuid.Nil, false
+}
+
+type cachedMatchMembers struct {
+	members   MatchMembers
+	found     bool
+	expiresAt time.Time
+}
+
+var matchMembersCache = struct {
+	sync.RWMutex
+	entries map[uuid.UUID]cachedMatchMembers
+}{entries: make(map[uuid.UUID]cachedMatchMembers)}
+
+func matchMembersKey(matchID uuid.UUID) string {
+	return matchMembersPrefix + matchID.String()
+}
+
+// GetMatchMembers returns a match's participants from the cache, loading them on a miss.
+// Unknown matches fail with ErrMatchNotFound.
+func GetMatchMembers(matchID uuid.UUID) (MatchMembers, error) {
+	matchMembersCache.RLock()
+	entry, ok := matchMembersCache.entries[matchID]
+	matchMembersCache.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		if !entry.found {
+			return MatchMembers{}, ErrMatchNotFound
+		}
+		return entry.members, nil
+	}
+
+	ctx := context.Background()
+	if database.RedisClient != nil {
+		if cached, err := database.RedisClient.Get(ctx, matchMembersKey(matchID)).Bytes(); err == nil {
+			var members MatchMembers
+			if json.Unmarshal(cached, &members) == nil {
+				storeMatchMembers(matchID, members, true)
+				return members, nil
+			}
+		}
+	}
+
+	var match models.Match
+	if err := database.DB.Select("id", "user1_id", "user2_id", "status").First(&match, "id = ?", matchID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Remembered locally only, so a bogus ID cannot hammer the database
+			storeMatchMembers(matchID, MatchMembers{}, false)
+			return MatchMembers{}, ErrMatchNotFound
+		}
+		return MatchMembers{}, err
+	}
+
+	members := MatchMembers{
+		User1ID: match.User1ID,
+		User2ID: match.User2ID,
+		Active:  match.Status == models.MatchStatusActive,
+	}
+	storeMatchMembers(matchID, members, true)
+	if database.RedisClient != nil {
+		encoded, _ := json.Marshal(members)
+		database.RedisClient.Set(ctx, matchMembersKey(matchID), encoded, matchMembersRedisTTL)
+	}
+	return members, nil
+}
+
+// ActiveMatchPeer returns the other participant of an active match the user is in
+func ActiveMatchPeer(matchID, userID uuid.UUID) (uuid.UUID, error) {
+	members, err := GetMatchMembers(matchID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	peerID, ok := members.Peer(userID)
+	if !ok || !members.Active {
+		return uuid.Nil, ErrMatchNotFound
+	}
+	return peerID, nil
+}
+
+// InvalidateMatchMembers drops a match from the cache after its status changed
+func InvalidateMatchMembers(matchID uuid.UUID) {
+	matchMembersCache.Lock()
+	delete(matchMembersCache.entries, matchID)
+	matchMembersCache.Unlock()
+
+	if database.RedisClient != nil {
+		database.RedisClient.Del(context.Background(), matchMembersKey(matchID))
+	}
+}
+
+func storeMatchMembers(matchID uuid.UUID, members MatchMembers, found bool) {
+	now := time.Now()
+
+	matchMembersCache.Lock()
+	defer matchMembersCache.Unlock()
+
+	if len(matchMembersCache.entries) >= matchMembersSweepAfter {
+		for id, entry := range matchMembersCache.entries {
+			if now.After(entry.expiresAt) {
+				delete(matchMembersCache.entries, id)
+			}
+		}
+	}
+	matchMembersCache.entries[matchID] = cachedMatchMembers{
+		members:   members,
+		found:     found,
+		expiresAt: now.Add(matchMembersLocalTTL),
+	}
+}
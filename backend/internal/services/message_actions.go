@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	messageEditWindow   = 15 * time.Minute // Senders can edit this long after sending
+	messageDeleteWindow = time.Hour        // Senders can delete for everyone this long after sending
+	maxReactionRunes    = 8                // One emoji, including skin tone and ZWJ sequences
+)
+
+// Errors returned by EditMessage, DeleteMessage and ReactToMessage
+var (
+	ErrMessageNotFound = errors.New("message not found")
+	ErrMessageNotOwner = errors.New("only the sender can change this message")
+	ErrMessageLocked   = errors.New("message can no longer be changed")
+)
+
+// MessageDeleteScope says who a deleted message disappears for
+type MessageDeleteScope string
+
+const (
+	MessageDeleteForMe       MessageDeleteScope = "me"
+	MessageDeleteForEveryone MessageDeleteScope = "everyone"
+)
+
+// lockMatchMessage loads a message of the match's current epoch for update
+func lockMatchMessage(tx *gorm.DB, match *models.Match, messageID uuid.UUID) (*models.Message, error) {
+	var message models.Message
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND match_id = ? AND epoch = ?", messageID, match.ID, match.Epoch).
+		First(&message).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	return &message, nil
+}
+
+// touchMessage gives a changed message the match's next sequence number as its UpdatedSeq,
+// so clients syncing from an older cursor pick the change up
+func touchMessage(tx *gorm.DB, message *models.Message) error {
+	var seq int64
+	if err := tx.Raw("UPDATE matches SET last_seq = last_seq + 1 WHERE id = ? RETURNING last_seq", message.MatchID).
+		Row().Scan(&seq); err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Message{}).Where("id = ?", message.ID).Update("updated_seq", seq).Error; err != nil {
+		return err
+	}
+	message.UpdatedSeq = seq
+	return nil
+}
+
+// EditMessage replaces the text (or media caption) of a message the user sent, within
+// messageEditWindow. The previous content is kept in message_edits.
+func EditMessage(userID, matchID, messageID uuid.UUID, content string) (*models.Message, error) {
+	match, err := activeMatchFor(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	content = strings.TrimSpace(content)
+	if !utf8.ValidString(content) {
+		return nil, invalidMessage("content is not valid UTF-8")
+	}
+	if utf8.RuneCountInString(content) > maxMessageLength {
+		return nil, invalidMessage("content is too long")
+	}
+
+	var message *models.Message
+	changed, blocked := false, false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if message, err = lockMatchMessage(tx, match, messageID); err != nil {
+			return err
+		}
+		if message.SenderID != userID {
+			return ErrMessageNotOwner
+		}
+		if message.DeletedForEveryoneAt != nil {
+			return fmt.Errorf("%w: it was deleted", ErrMessageLocked)
+		}
+		switch message.MessageType {
+		case models.MessageTypeText:
+			if content == "" {
+				return invalidMessage("text messages need content")
+			}
+		case models.MessageTypePhoto, models.MessageTypeVideo, models.MessageTypeVoice, models.MessageTypePromptReply:
+			// Only the caption (or the comment on the quoted answer) changes
+		default:
+			return fmt.Errorf("%w: %s messages cannot be edited", ErrMessageLocked, message.MessageType)
+		}
+		if time.Since(message.CreatedAt) > messageEditWindow {
+			return fmt.Errorf("%w: messages can be edited for %d minutes", ErrMessageLocked, int(messageEditWindow.Minutes()))
+		}
+		if content == message.Content {
+			return nil
+		}
+
+		// Edits are screened like new messages; only a clean or warned edit is applied
+		screeningInput := ScreeningInput{
+			MatchID:     message.MatchID,
+			SenderID:    message.SenderID,
+			ReceiverID:  message.ReceiverID,
+			MessageType: message.MessageType,
+			Content:     content,
+		}
+		screening := ScreenMessageUpdate(screeningInput)
+		if err := recordFlaggedMessage(tx, screeningInput, &message.ID, screening); err != nil {
+			return err
+		}
+		if screening.Action == models.ScreeningActionBlock {
+			// Keep the record of the attempt, reject the edit
+			blocked = true
+			return nil
+		}
+
+		updates := map[string]interface{}{"content": content}
+		if screening.Action == models.ScreeningActionWarn {
+			if message.Metadata == nil {
+				message.Metadata = models.JSONMap{}
+			}
+			message.Metadata["safety_warning"] = screening.Categories()
+			updates["metadata"] = message.Metadata
+		}
+
+		now := time.Now()
+		updates["edited_at"] = now
+		if err := tx.Create(&models.MessageEdit{
+			MessageID:       message.ID,
+			EditorID:        userID,
+			PreviousContent: message.Content,
+			EditedAt:        now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(message).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := touchMessage(tx, message); err != nil {
+			return err
+		}
+		message.Content = content
+		message.EditedAt = &now
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrMessageScreened
+	}
+
+	if changed {
+		PublishMessageEvent("message_edited", *message, map[string]interface{}{
+			"content":   message.Content,
+			"edited_at": message.EditedAt,
+		})
+	}
+	return message, nil
+}
+
+// MessageEditHistory returns the earlier versions of a message, oldest first
+func MessageEditHistory(userID, matchID, messageID uuid.UUID) ([]models.MessageEdit, error) {
+	match, err := activeMatchFor(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var message models.Message
+	if err := database.DB.Select("id", "deleted_for_everyone_at").
+		Where("id = ? AND match_id = ? AND epoch = ?", messageID, match.ID, match.Epoch).
+		First(&message).Error; err != nil {
+		return nil, ErrMessageNotFound
+	}
+
+	edits := make([]models.MessageEdit, 0)
+	if message.DeletedForEveryoneAt != nil {
+		return edits, nil
+	}
+	if err := database.DB.Where("message_id = ?", messageID).Order("edited_at ASC").Find(&edits).Error; err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// DeleteMessage hides a message from the user (MessageDeleteForMe), or turns a message the
+// user sent into a tombstone for both participants (MessageDeleteForEveryone, within
+// messageDeleteWindow). A tombstone loses its content, media, edit history and reactions.
+func DeleteMessage(userID, matchID, messageID uuid.UUID, scope MessageDeleteScope) (*models.Message, error) {
+	match, err := activeMatchFor(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if scope != MessageDeleteForMe && scope != MessageDeleteForEveryone {
+		return nil, invalidMessage("scope must be me or everyone")
+	}
+
+	var message *models.Message
+	var mediaKey string
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if message, err = lockMatchMessage(tx, match, messageID); err != nil {
+			return err
+		}
+
+		if scope == MessageDeleteForMe {
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.MessageDeletion{MessageID: message.ID, UserID: userID}).Error
+		}
+
+		if message.SenderID != userID {
+			return ErrMessageNotOwner
+		}
+		if message.DeletedForEveryoneAt != nil {
+			return nil
+		}
+		switch message.MessageType {
+		case models.MessageTypeGift:
+			return fmt.Errorf("%w: gifts cannot be unsent", ErrMessageLocked)
+		case models.MessageTypeBunaInvite:
+			return fmt.Errorf("%w: cancel the buna instead", ErrMessageLocked)
+		}
+		if time.Since(message.CreatedAt) > messageDeleteWindow {
+			return fmt.Errorf("%w: messages can be deleted for everyone for %d minutes", ErrMessageLocked, int(messageDeleteWindow.Minutes()))
+		}
+
+		now := time.Now()
+		if err := tx.Model(message).Updates(map[string]interface{}{
+			"content":                 "",
+			"media_url":               "",
+			"metadata":                models.JSONMap{},
+			"deleted_for_everyone_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageEdit{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("message_id = ?", message.ID).Delete(&models.MessageReaction{}).Error; err != nil {
+			return err
+		}
+		if strings.HasPrefix(message.MediaURL, chatMediaKeyPrefix) {
+			mediaKey = message.MediaURL
+			if err := tx.Where("message_id = ?", message.ID).Delete(&models.ChatMedia{}).Error; err != nil {
+				return err
+			}
+		}
+		if err := touchMessage(tx, message); err != nil {
+			return err
+		}
+
+		message.Content = ""
+		message.MediaURL = ""
+		message.Metadata = models.JSONMap{}
+		message.DeletedForEveryoneAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if mediaKey != "" {
+		go func() {
+			if err := database.DeleteObject(context.Background(), config.Cfg.S3BucketChat, mediaKey); err != nil {
+				log.Printf("⚠️ Failed to delete chat media %s: %v", mediaKey, err)
+			}
+		}()
+	}
+
+	data := map[string]interface{}{"scope": scope}
+	if scope == MessageDeleteForMe {
+		// Only the user's other devices need to know
+		PublishUserMessageEvent(userID, "message_deleted", *message, data)
+	} else {
+		PublishMessageEvent("message_deleted", *message, data)
+	}
+	return message, nil
+}
+
+// validateReaction checks that a reaction is a single emoji rather than text
+func validateReaction(emoji string) error {
+	if emoji == "" {
+		return invalidMessage("emoji is required")
+	}
+	if !utf8.ValidString(emoji) || utf8.RuneCountInString(emoji) > maxReactionRunes {
+		return invalidMessage("reaction must be a single emoji")
+	}
+	for _, r := range emoji {
+		if r < 0x80 || unicode.IsLetter(r) || unicode.IsSpace(r) {
+			return invalidMessage("reaction must be a single emoji")
+		}
+	}
+	return nil
+}
+
+// ReactToMessage sets the user's reaction on a message (an empty emoji removes it) and
+// returns the message's reactions
+func ReactToMessage(userID, matchID, messageID uuid.UUID, emoji string) ([]models.MessageReaction, error) {
+	match, err := activeMatchFor(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	emoji = strings.TrimSpace(emoji)
+	if emoji != "" {
+		if err := validateReaction(emoji); err != nil {
+			return nil, err
+		}
+	}
+
+	var message models.Message
+	if err := database.DB.Where("id = ? AND match_id = ? AND epoch = ?", messageID, match.ID, match.Epoch).
+		First(&message).Error; err != nil {
+		return nil, ErrMessageNotFound
+	}
+	if message.DeletedForEveryoneAt != nil {
+		return nil, fmt.Errorf("%w: it was deleted", ErrMessageLocked)
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if emoji == "" {
+			if err := tx.Where("message_id = ? AND user_id = ?", message.ID, userID).
+				Delete(&models.MessageReaction{}).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"emoji": emoji, "created_at": time.Now()}),
+		}).Create(&models.MessageReaction{MessageID: message.ID, UserID: userID, Emoji: emoji}).Error; err != nil {
+			return err
+		}
+		return touchMessage(tx, &message)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reactions := make([]models.MessageReaction, 0)
+	if err := database.DB.Where("message_id = ?", message.ID).Order("created_at ASC").Find(&reactions).Error; err != nil {
+		return nil, err
+	}
+
+	PublishMessageEvent("reaction", message, map[string]interface{}{
+		"user_id":   userID,
+		"emoji":     emoji,
+		"reactions": reactions,
+	})
+	return reactions, nil
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"lomi-backend/config"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrMessageScreened is returned when the content filter blocks a message (or an edit)
+var ErrMessageScreened = errors.New("message blocked by the content filter")
+
+const maxScreeningMatchLength = 100 // Runes of matched text kept per hit
+
+// ScreeningInput is the part of a message the content filter looks at
+type ScreeningInput struct {
+	MatchID     uuid.UUID
+	SenderID    uuid.UUID
+	ReceiverID  uuid.UUID
+	MessageType models.MessageType
+	Content     string
+	Fields      []string // Other text and URLs the sender supplied: sticker URLs, buna cafe details
+}
+
+// texts returns the content and fields that have any text
+func (in ScreeningInput) texts() []string {
+	texts := make([]string, 0, 1+len(in.Fields))
+	for _, text := range append([]string{in.Content}, in.Fields...) {
+		if strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+// ScreeningHit is one rule matching a message
+type ScreeningHit struct {
+	Rule     string                 `json:"rule"`
+	Category string                 `json:"category"` // contact_info, payment, link, scam
+	Action   models.ScreeningAction `json:"action"`
+	Match    string                 `json:"match"`
+}
+
+// ScreeningResult is the most severe action of all hits
+type ScreeningResult struct {
+	Action models.ScreeningAction
+	Hits   []ScreeningHit
+}
+
+// Categories lists the distinct categories of the hits, in order
+func (r ScreeningResult) Categories() []string {
+	seen := make(map[string]bool)
+	categories := make([]string, 0, len(r.Hits))
+	for _, hit := range r.Hits {
+		if !seen[hit.Category] {
+			seen[hit.Category] = true
+			categories = append(categories, hit.Category)
+		}
+	}
+	return categories
+}
+
+// MessageScreener is one stage of the content filter. Screeners report hits with their
+// default action; MESSAGE_SCREENING_ACTIONS can override the action per rule.
+type MessageScreener interface {
+	Screen(in ScreeningInput) []ScreeningHit
+}
+
+var (
+	messageScreenersMu sync.RWMutex
+	messageScreeners   = []MessageScreener{PatternScreener{Rules: DefaultScreeningRules}}
+)
+
+// RegisterMessageScreener adds a stage to the content filter (e.g. an ML classifier)
+func RegisterMessageScreener(s MessageScreener) {
+	messageScreenersMu.Lock()
+	defer messageScreenersMu.Unlock()
+	messageScreeners = append(messageScreeners, s)
+}
+
+// ScreeningRule flags text matching Pattern
+type ScreeningRule struct {
+	Name     string
+	Category string
+	Action   models.ScreeningAction
+	Pattern  *regexp.Regexp
+}
+
+// PatternScreener screens text against regular expressions
+type PatternScreener struct {
+	Rules []ScreeningRule
+}
+
+func (s PatternScreener) Screen(in ScreeningInput) []ScreeningHit {
+	var hits []ScreeningHit
+	texts := in.texts()
+	for _, rule := range s.Rules {
+		for _, text := range texts {
+			if match := rule.Pattern.FindString(text); match != "" {
+				hits = append(hits, ScreeningHit{
+					Rule:     rule.Name,
+					Category: rule.Category,
+					Action:   rule.Action,
+					Match:    strings.TrimSpace(match),
+				})
+				break
+			}
+		}
+	}
+	return hits
+}
+
+// DefaultScreeningRules catch contact details and payment handles being moved off the app,
+// and common scam openers, in English and Amharic
+var DefaultScreeningRules = []ScreeningRule{
+	{
+		// Ethiopian mobile numbers: +251 9x / 7x, 09x / 07x, with spaces, dots or dashes
+		Name: "phone_number", Category: "contact_info", Action: models.ScreeningActionWarn,
+		Pattern: regexp.MustCompile(`(?:\+|00)?251[\s.-]*[79](?:[\s.-]*\d){8}|\b0[79](?:[\s.-]*\d){8}\b`),
+	},
+	{
+		Name: "international_phone", Category: "contact_info", Action: models.ScreeningActionWarn,
+		Pattern: regexp.MustCompile(`\+\d(?:[\s.-]*\d){9,13}`),
+	},
+	{
+		Name: "messenger_handle", Category: "contact_info", Action: models.ScreeningActionWarn,
+		Pattern: regexp.MustCompile(`(?i)\b(?:t\.me|telegram\.me|wa\.me)/\w+|(?:^|\s)@[a-z][a-z0-9_]{4,31}\b`),
+	},
+	{
+		Name: "telebirr", Category: "payment", Action: models.ScreeningActionHold,
+		Pattern: regexp.MustCompile(`(?i)tele\s*birr|ቴሌ\s*ብር`),
+	},
+	{
+		// CBE account numbers are 13 digits starting with 1000
+		Name: "cbe_account", Category: "payment", Action: models.ScreeningActionHold,
+		Pattern: regexp.MustCompile(`\b1000\d{9}\b|(?i:\bcbe\b|commercial bank|ንግድ\s*ባንክ)\D{0,30}\d{8,}`),
+	},
+	{
+		Name: "bank_account", Category: "payment", Action: models.ScreeningActionHold,
+		Pattern: regexp.MustCompile(`(?i:account\s*(?:no|number|#)|acc\s*no|አካውንት|የሂሳብ\s*ቁጥር|ሂሳብ\s*ቁጥር)\D{0,20}\d{6,}`),
+	},
+	{
+		Name: "external_link", Category: "link", Action: models.ScreeningActionWarn,
+		Pattern: regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+|\b[a-z0-9][a-z0-9-]*\.(?:com|net|org|io|me|et|ly|link|xyz|app|site|online|info)\b(?:/\S*)?`),
+	},
+	{
+		Name: "crypto", Category: "scam", Action: models.ScreeningActionWarn,
+		Pattern: regexp.MustCompile(`(?i)\b(?:crypto(?:currency)?|bitcoin|btc|usdt|binance|forex)\b|ቢትኮይን|ክሪፕቶ`),
+	},
+	{
+		Name: "scam_phrase_en", Category: "scam", Action: models.ScreeningActionHold,
+		Pattern: regexp.MustCompile(`(?i)\b(?:send (?:me )?(?:some )?money|western union|moneygram|gift ?cards?|investment (?:opportunity|plan)|double your money|verification fee|customs fee|pay for my (?:ticket|visa|transport|taxi)|lend me|loan me|i need money)\b`),
+	},
+	{
+		// "send me money/birr", "top up my (airtime) card", "invest", "lend me"
+		Name: "scam_phrase_am", Category: "scam", Action: models.ScreeningActionHold,
+		Pattern: regexp.MustCompile(`(?:ብር|ገንዘብ)\s*(?:ላክልኝ|ላኪልኝ|ላኩልኝ)|ካርድ\s*(?:ሙላልኝ|ሙይልኝ|ላክልኝ|ላኪልኝ)|ኢንቨስት|አበድረኝ|አበድሪኝ`),
+	},
+}
+
+var (
+	screeningOverridesOnce sync.Once
+	screeningOverrides     map[string]models.ScreeningAction
+)
+
+// screeningActionOverrides parses MESSAGE_SCREENING_ACTIONS, e.g. "phone_number=hold,external_link=allow"
+func screeningActionOverrides() map[string]models.ScreeningAction {
+	screeningOverridesOnce.Do(func() {
+		screeningOverrides = make(map[string]models.ScreeningAction)
+		if config.Cfg == nil {
+			return
+		}
+		for _, pair := range strings.Split(config.Cfg.MessageScreeningActions, ",") {
+			rule, action, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			a := models.ScreeningAction(strings.TrimSpace(action))
+			if screeningSeverity(a) < 0 {
+				log.Printf("⚠️ Ignoring unknown screening action %q for rule %s", action, rule)
+				continue
+			}
+			screeningOverrides[strings.TrimSpace(rule)] = a
+		}
+	})
+	return screeningOverrides
+}
+
+func screeningSeverity(a models.ScreeningAction) int {
+	switch a {
+	case models.ScreeningActionAllow:
+		return 0
+	case models.ScreeningActionWarn:
+		return 1
+	case models.ScreeningActionHold:
+		return 2
+	case models.ScreeningActionBlock:
+		return 3
+	}
+	return -1
+}
+
+// ScreenMessage runs every screener over a message. Hits whose action is allow are dropped.
+func ScreenMessage(in ScreeningInput) ScreeningResult {
+	result := ScreeningResult{Action: models.ScreeningActionAllow}
+	if len(in.texts()) == 0 {
+		return result
+	}
+
+	messageScreenersMu.RLock()
+	screeners := messageScreeners
+	messageScreenersMu.RUnlock()
+
+	overrides := screeningActionOverrides()
+	for _, screener := range screeners {
+		for _, hit := range screener.Screen(in) {
+			if action, ok := overrides[hit.Rule]; ok {
+				hit.Action = action
+			}
+			if hit.Action == models.ScreeningActionAllow {
+				continue
+			}
+			if utf8.RuneCountInString(hit.Match) > maxScreeningMatchLength {
+				hit.Match = string([]rune(hit.Match)[:maxScreeningMatchLength])
+			}
+			result.Hits = append(result.Hits, hit)
+			if screeningSeverity(hit.Action) > screeningSeverity(result.Action) {
+				result.Action = hit.Action
+			}
+		}
+	}
+	return result
+}
+
+// ScreenMessageUpdate screens text added to a message that was already delivered (an edit,
+// a buna reschedule or feedback). There is no held version of a change to wait for review,
+// so a hold is treated as a block: the change is rejected and recorded as blocked.
+func ScreenMessageUpdate(in ScreeningInput) ScreeningResult {
+	result := ScreenMessage(in)
+	if result.Action == models.ScreeningActionHold {
+		result.Action = models.ScreeningActionBlock
+	}
+	return result
+}
+
+// recordFlaggedMessage stores a screening result for admin review
+func recordFlaggedMessage(tx *gorm.DB, in ScreeningInput, messageID *uuid.UUID, result ScreeningResult) error {
+	if len(result.Hits) == 0 {
+		return nil
+	}
+
+	flagged := models.FlaggedMessage{
+		MessageID:   messageID,
+		MatchID:     in.MatchID,
+		SenderID:    in.SenderID,
+		ReceiverID:  in.ReceiverID,
+		MessageType: in.MessageType,
+		Content:     strings.Join(in.texts(), "\n"),
+		Action:      result.Action,
+		Rules:       make(models.JSONStringArray, 0, len(result.Hits)),
+		Matches:     make(models.JSONStringArray, 0, len(result.Hits)),
+	}
+	for _, hit := range result.Hits {
+		flagged.Rules = append(flagged.Rules, hit.Rule)
+		flagged.Matches = append(flagged.Matches, hit.Match)
+	}
+	return tx.Create(&flagged).Error
+}
+
+// ErrFlaggedMessageNotFound is returned by ReviewFlaggedMessage
+var ErrFlaggedMessageNotFound = errors.New("flagged message not found")
+
+// ReviewFlaggedMessage records an admin decision. Releasing a held message delivers it to
+// the receiver (with a push if offline); rejecting keeps it from them for good. banSender
+// deactivates the sender, as ReviewReport does for reports.
+func ReviewFlaggedMessage(adminID, flaggedID uuid.UUID, decision models.FlaggedMessageDecision, banSender bool) (*models.FlaggedMessage, error) {
+	switch decision {
+	case models.FlaggedMessageDecisionRelease, models.FlaggedMessageDecisionReject, models.FlaggedMessageDecisionDismiss:
+	default:
+		return nil, invalidMessage("decision must be release, reject or dismiss")
+	}
+
+	var flagged models.FlaggedMessage
+	var released *models.Message
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&flagged, "id = ?", flaggedID).Error; err != nil {
+			return ErrFlaggedMessageNotFound
+		}
+		if decision == models.FlaggedMessageDecisionRelease {
+			if flagged.Action != models.ScreeningActionHold || flagged.MessageID == nil {
+				return invalidMessage("only held messages can be released")
+			}
+			var message models.Message
+			result := tx.Model(&models.Message{}).
+				Where("id = ? AND held = ?", *flagged.MessageID, true).
+				Update("held", false)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				if err := tx.First(&message, "id = ?", *flagged.MessageID).Error; err != nil {
+					return err
+				}
+				// The receiver may have synced past the message's seq while it was held
+				if err := touchMessage(tx, &message); err != nil {
+					return err
+				}
+				released = &message
+			}
+		}
+
+		now := time.Now()
+		flagged.IsReviewed = true
+		flagged.ReviewedBy = &adminID
+		flagged.ReviewedAt = &now
+		flagged.Decision = decision
+		if err := tx.Save(&flagged).Error; err != nil {
+			return err
+		}
+
+		if banSender {
+			return tx.Model(&models.User{}).Where("id = ?", flagged.SenderID).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if released != nil {
+		DeliverChatMessage(*released)
+	}
+	return &flagged, nil
+}
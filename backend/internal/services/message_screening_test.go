@@ -0,0 +1,104 @@
+package services
+
+import (
+	"lomi-backend/internal/models"
+	"testing"
+)
+
+func TestDefaultScreeningRules(t *testing.T) {
+	screener := PatternScreener{Rules: DefaultScreeningRules}
+
+	tests := []struct {
+		name    string
+		content string
+		rule    string // Expected rule; empty means no rule may match
+	}{
+		// English
+		{"local phone", "call me on 0911 23 45 67", "phone_number"},
+		{"ethiopian phone with code", "my number is +251-911-234567", "phone_number"},
+		{"international phone", "text me on +44 7700 900123", "international_phone"},
+		{"telegram link", "find me at t.me/selam_abebe", "messenger_handle"},
+		{"handle", "add me @selam_abebe", "messenger_handle"},
+		{"telebirr", "just send it via telebirr", "telebirr"},
+		{"cbe account", "CBE 12345678901", "cbe_account"},
+		{"cbe account number", "it is 1000123456789", "cbe_account"},
+		{"bank account", "account number: 12345678", "bank_account"},
+		{"link", "check https://bit.ly/x", "external_link"},
+		{"bare domain", "see lomi.et/promo", "external_link"},
+		{"crypto", "you should invest in bitcoin", "crypto"},
+		{"send money", "please send me money", "scam_phrase_en"},
+		{"gift cards", "buy me some gift cards", "scam_phrase_en"},
+		{"plans", "Let's get buna tomorrow at 10", ""},
+		{"numbers in text", "I was born in 1995 and have 2 sisters", ""},
+		{"place and time", "Meet at 4 kilo around 3:30", ""},
+		{"birthday card", "I love birthday cards", ""},
+
+		// Amharic
+		{"amharic phone", "ስልኬ 0912345678 ነው", "phone_number"},
+		{"amharic telegram handle", "ቴሌግራም @selam_abebe", "messenger_handle"},
+		{"amharic telebirr", "በቴሌ ብር ላኪልኝ", "telebirr"},
+		{"amharic cbe", "ንግድ ባንክ 100012345678", "cbe_account"},
+		{"amharic account", "የሂሳብ ቁጥር 1234567", "bank_account"},
+		{"amharic crypto", "ቢትኮይን ግዛ", "crypto"},
+		{"amharic send money", "ብር ላክልኝ", "scam_phrase_am"},
+		{"amharic top up", "ካርድ ሙላልኝ", "scam_phrase_am"},
+		{"amharic lend me", "እባክህ አበድረኝ", "scam_phrase_am"},
+		{"amharic greeting", "ሰላም እንዴት ነሽ?", ""},
+		{"amharic plans", "ቡና እንጠጣ ነገ", ""},
+		{"amharic price", "ዋጋው 50 ብር ነው", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := screener.Screen(ScreeningInput{MessageType: models.MessageTypeText, Content: tt.content})
+			if tt.rule == "" {
+				if len(hits) > 0 {
+					t.Fatalf("%q matched %s (%q), want no match", tt.content, hits[0].Rule, hits[0].Match)
+				}
+				return
+			}
+			for _, hit := range hits {
+				if hit.Rule == tt.rule {
+					return
+				}
+			}
+			t.Fatalf("%q did not match %s (hits: %+v)", tt.content, tt.rule, hits)
+		})
+	}
+}
+
+func TestScreenMessageFields(t *testing.T) {
+	result := ScreenMessage(ScreeningInput{
+		MessageType: models.MessageTypeBunaInvite,
+		Content:     "Coffee on Saturday?",
+		Fields:      []string{"Tomoca", "", "pay the table with telebirr"},
+	})
+	if result.Action != models.ScreeningActionHold {
+		t.Fatalf("action = %s, want hold from the note field", result.Action)
+	}
+
+	result = ScreenMessage(ScreeningInput{
+		MessageType: models.MessageTypeSticker,
+		Fields:      []string{"https://stickers.example.com/buna.png"},
+	})
+	if result.Action != models.ScreeningActionWarn {
+		t.Fatalf("sticker URL action = %s, want warn", result.Action)
+	}
+
+	result = ScreenMessage(ScreeningInput{MessageType: models.MessageTypeText, Content: "ሰላም", Fields: []string{"Tomoca"}})
+	if result.Action != models.ScreeningActionAllow {
+		t.Fatalf("clean action = %s, want allow", result.Action)
+	}
+}
+
+func TestScreenMessageUpdateBlocksHolds(t *testing.T) {
+	result := ScreenMessageUpdate(ScreeningInput{MessageType: models.MessageTypeText, Content: "ብር ላክልኝ"})
+	if result.Action != models.ScreeningActionBlock {
+		t.Fatalf("action = %s, want block", result.Action)
+	}
+
+	result = ScreenMessageUpdate(ScreeningInput{MessageType: models.MessageTypeText, Content: "see lomi.et"})
+	if result.Action != models.ScreeningActionWarn {
+		t.Fatalf("action = %s, want warn", result.Action)
+	}
+}
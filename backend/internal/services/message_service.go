@@ -0,0 +1,355 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"lomi-backend/internal/ratelimit"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	maxMessageLength         = 2000 // Runes of text or caption
+	maxClientMessageIDLength = 64   // messages.client_message_id
+)
+
+// Errors returned by SendChatMessage; handlers map them to HTTP statuses or WS error frames
+var (
+	ErrMatchNotFound      = errors.New("match not found")
+	ErrMessageBlocked     = errors.New("cannot send message: user is blocked")
+	ErrMessageRateLimited = errors.New("rate limit exceeded")
+	ErrInvalidMessage     = errors.New("invalid message")
+)
+
+// MessageValidationError explains why a message was rejected. It matches ErrInvalidMessage.
+type MessageValidationError struct {
+	Reason string
+}
+
+func (e *MessageValidationError) Error() string { return e.Reason }
+
+func (e *MessageValidationError) Is(target error) bool { return target == ErrInvalidMessage }
+
+func invalidMessage(reason string) error {
+	return &MessageValidationError{Reason: reason}
+}
+
+// MessageDelivery pushes stored messages to open sockets. The WebSocket hub registers
+// itself at startup with SetMessageDelivery.
+type MessageDelivery interface {
+	DeliverMessage(message models.Message)
+	DeliverMessageEvent(eventType string, message models.Message, data interface{}) // e.g. buna_update
+	DeliverUserMessageEvent(userID uuid.UUID, eventType string, message models.Message, data interface{})
+	IsConnected(userID uuid.UUID) bool
+}
+
+var messageDelivery MessageDelivery
+
+// SetMessageDelivery registers the realtime delivery used by SendChatMessage
+func SetMessageDelivery(d MessageDelivery) {
+	messageDelivery = d
+}
+
+// SendMessageInput is a message as sent by a client over REST or WebSocket
+type SendMessageInput struct {
+	MatchID         uuid.UUID
+	MessageType     models.MessageType
+	Content         string
+	MediaURL        string    // Stickers only; photos, videos and voice notes use MediaID
+	MediaID         uuid.UUID // Upload from CreateChatMediaUpload
+	Metadata        map[string]interface{}
+	ClientMessageID string // Optional; a resend with the same ID returns the stored message
+}
+
+// SendChatMessage is the single path for user-sent chat messages: it checks the sender
+// belongs to the active match, blocks, the message rate limit and the content, screens it
+// (ScreenMessage), stores the message, fans it out to both participants' sockets and pushes
+// to the receiver if offline. Held messages reach only the sender until released.
+// duplicate is true when ClientMessageID matched an already stored message (nothing is sent again).
+func SendChatMessage(senderID uuid.UUID, in SendMessageInput) (message *models.Message, duplicate bool, err error) {
+	if len(in.ClientMessageID) > maxClientMessageIDLength {
+		return nil, false, invalidMessage("client_message_id is too long")
+	}
+	if existing, ok := findClientMessage(senderID, in.ClientMessageID); ok {
+		return existing, true, nil
+	}
+
+	// Membership: the sender must be a participant of an active match
+	match, err := activeMatchFor(in.MatchID, senderID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	receiverID := match.User1ID
+	if match.User1ID == senderID {
+		receiverID = match.User2ID
+	}
+
+	// Blocks in either direction
+	var blockCount int64
+	database.DB.Model(&models.Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", receiverID, senderID, senderID, receiverID).
+		Count(&blockCount)
+	if blockCount > 0 {
+		return nil, false, ErrMessageBlocked
+	}
+
+	if allowed, _ := ratelimit.Allow(context.Background(), ratelimit.Message, senderID); !allowed {
+		return nil, false, ErrMessageRateLimited
+	}
+
+	if in.MessageType == "" {
+		in.MessageType = models.MessageTypeText
+	}
+	if err := validateMessageContent(&in); err != nil {
+		return nil, false, err
+	}
+
+	if in.MessageType == models.MessageTypeBunaInvite {
+		// Only the proposal fields are taken from the client; the state is built here
+		if in.Metadata, err = newBunaInviteMetadata(senderID, in.Metadata); err != nil {
+			return nil, false, err
+		}
+	}
+	if in.MessageType == models.MessageTypePromptReply {
+		if in.Metadata, err = newPromptReplyMetadata(receiverID, in.Metadata); err != nil {
+			return nil, false, err
+		}
+	}
+
+	screeningInput := ScreeningInput{
+		MatchID:     match.ID,
+		SenderID:    senderID,
+		ReceiverID:  receiverID,
+		MessageType: in.MessageType,
+		Content:     in.Content,
+		Fields:      screeningFields(in),
+	}
+	screening := ScreenMessage(screeningInput)
+	if screening.Action == models.ScreeningActionBlock {
+		if err := recordFlaggedMessage(database.DB, screeningInput, nil, screening); err != nil {
+			log.Printf("⚠️ Failed to record blocked message: %v", err)
+		}
+		return nil, false, ErrMessageScreened
+	}
+
+	var media *models.ChatMedia
+	if IsChatMediaType(in.MessageType) {
+		if media, err = attachableChatMedia(in.MediaID, senderID, match.ID, in.MessageType); err != nil {
+			return nil, false, err
+		}
+	}
+
+	msg := models.Message{
+		MatchID:     match.ID,
+		SenderID:    senderID,
+		ReceiverID:  receiverID,
+		MessageType: in.MessageType,
+		Content:     in.Content,
+		MediaURL:    in.MediaURL,
+		IsRead:      false,
+		Held:        screening.Action == models.ScreeningActionHold,
+	}
+	if in.Metadata != nil {
+		msg.Metadata = models.JSONMap(in.Metadata)
+	}
+	if in.ClientMessageID != "" {
+		msg.ClientMessageID = &in.ClientMessageID
+	}
+	if screening.Action == models.ScreeningActionWarn {
+		// Clients show the receiver a safety banner for these categories
+		if msg.Metadata == nil {
+			msg.Metadata = models.JSONMap{}
+		}
+		msg.Metadata["safety_warning"] = screening.Categories()
+	}
+	if media != nil {
+		// The message keeps the object key; responses sign it (PresignChatMessages)
+		msg.MediaURL = media.ObjectKey
+		if msg.Metadata == nil {
+			msg.Metadata = models.JSONMap{}
+		}
+		msg.Metadata["media_id"] = media.ID.String()
+		msg.Metadata["content_type"] = media.ContentType
+		msg.Metadata["size_bytes"] = media.SizeBytes
+		if media.DurationSeconds > 0 {
+			msg.Metadata["duration_seconds"] = media.DurationSeconds
+		}
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&msg).Error; err != nil {
+			return err
+		}
+		if err := recordFlaggedMessage(tx, screeningInput, &msg.ID, screening); err != nil {
+			return err
+		}
+		if media == nil {
+			return nil
+		}
+		// Each upload can be sent once
+		result := tx.Model(&models.ChatMedia{}).
+			Where("id = ? AND status = ?", media.ID, models.ChatMediaStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.ChatMediaStatusAttached,
+				"message_id": msg.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return invalidMessage("media was already sent")
+		}
+		return nil
+	})
+	if err != nil {
+		// A concurrent resend may have stored it first
+		if existing, ok := findClientMessage(senderID, in.ClientMessageID); ok {
+			return existing, true, nil
+		}
+		return nil, false, err
+	}
+
+	DeliverChatMessage(msg)
+
+	return &msg, false, nil
+}
+
+// PublishChatMessage fans a stored message out to both participants' sockets (no push)
+func PublishChatMessage(msg models.Message) {
+	if messageDelivery != nil {
+		msg.MediaURL = ChatMediaURL(msg.MediaURL)
+		messageDelivery.DeliverMessage(msg)
+	}
+}
+
+// PublishMessageEvent sends an event about a stored message (such as a buna state change)
+// to both participants' sockets
+func PublishMessageEvent(eventType string, msg models.Message, data interface{}) {
+	if messageDelivery != nil {
+		msg.MediaURL = ChatMediaURL(msg.MediaURL)
+		messageDelivery.DeliverMessageEvent(eventType, msg, data)
+	}
+}
+
+// PublishUserMessageEvent sends an event about a stored message to one participant's
+// sockets only (such as a delete-for-me reaching their other devices)
+func PublishUserMessageEvent(userID uuid.UUID, eventType string, msg models.Message, data interface{}) {
+	if messageDelivery != nil {
+		msg.MediaURL = ChatMediaURL(msg.MediaURL)
+		messageDelivery.DeliverUserMessageEvent(userID, eventType, msg, data)
+	}
+}
+
+// DeliverChatMessage fans a stored message out to both participants' sockets and sends a
+// push to the receiver when they have no socket open. A held message goes to the sender only.
+func DeliverChatMessage(msg models.Message) {
+	PublishChatMessage(msg)
+
+	receiverOnline := false
+	if messageDelivery != nil {
+		receiverOnline = messageDelivery.IsConnected(msg.ReceiverID)
+	}
+
+	if receiverOnline || msg.Held || NotificationSvc == nil {
+		return
+	}
+	go func() {
+		var sender models.User
+		if err := database.DB.Select("id", "name").First(&sender, "id = ?", msg.SenderID).Error; err != nil {
+			return
+		}
+		if err := NotificationSvc.NotifyNewMessage(msg, sender); err != nil {
+			log.Printf("⚠️ Failed to send message push for %s: %v", msg.ID, err)
+		}
+	}()
+}
+
+// validateMessageContent checks the fields each message type needs
+func validateMessageContent(in *SendMessageInput) error {
+	in.Content = strings.TrimSpace(in.Content)
+	in.MediaURL = strings.TrimSpace(in.MediaURL)
+
+	if !utf8.ValidString(in.Content) {
+		return invalidMessage("content is not valid UTF-8")
+	}
+	if utf8.RuneCountInString(in.Content) > maxMessageLength {
+		return invalidMessage("content is too long")
+	}
+
+	switch in.MessageType {
+	case models.MessageTypeText:
+		if in.Content == "" {
+			return invalidMessage("text messages need content")
+		}
+		if in.MediaURL != "" {
+			return invalidMessage("text messages cannot carry media")
+		}
+	case models.MessageTypePhoto, models.MessageTypeVideo, models.MessageTypeVoice:
+		// Content is an optional caption; the file comes from an upload (see CreateChatMediaUpload)
+		if in.MediaID == uuid.Nil {
+			return invalidMessage(string(in.MessageType) + " messages need a media_id")
+		}
+		if in.MediaURL != "" {
+			return invalidMessage("upload media first and send its media_id")
+		}
+	case models.MessageTypeSticker:
+		if in.Content == "" && in.MediaURL == "" {
+			return invalidMessage("sticker messages need a sticker")
+		}
+		if strings.HasPrefix(in.MediaURL, chatMediaKeyPrefix) {
+			return invalidMessage("invalid sticker")
+		}
+	case models.MessageTypeBunaInvite:
+		// Details travel in metadata
+	case models.MessageTypePromptReply:
+		// Content is an optional comment; the quoted answer travels in metadata
+		if in.MediaURL != "" {
+			return invalidMessage("prompt replies cannot carry media")
+		}
+	case models.MessageTypeGift:
+		return invalidMessage("gift messages are created when the gift is sent")
+	default:
+		return invalidMessage("unsupported message type")
+	}
+	return nil
+}
+
+// screeningFields returns what the sender supplied besides the content: a sticker URL, or
+// the cafe and note of a buna invite
+func screeningFields(in SendMessageInput) []string {
+	var fields []string
+	if in.MediaURL != "" {
+		fields = append(fields, in.MediaURL)
+	}
+	if in.MessageType == models.MessageTypeBunaInvite {
+		for _, key := range []string{"cafe_name", "cafe_address", "note"} {
+			if value, _ := in.Metadata[key].(string); value != "" {
+				fields = append(fields, value)
+			}
+		}
+	}
+	return fields
+}
+
+// findClientMessage returns the message a sender already stored under a client message ID
+func findClientMessage(senderID uuid.UUID, clientMessageID string) (*models.Message, bool) {
+	if clientMessageID == "" {
+		return nil, false
+	}
+	var message models.Message
+	if err := database.DB.Where("sender_id = ? AND client_message_id = ?", senderID, clientMessageID).
+		First(&message).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("⚠️ Failed to look up client message %s: %v", clientMessageID, err)
+		}
+		return nil, false
+	}
+	return &message, true
+}
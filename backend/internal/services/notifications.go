@@ -37,10 +37,15 @@ func InitNotificationService(telegramBotToken, onesignalAppID, onesignalAPIKey,
 type NotificationType string
 
 const (
-	NotificationTypeNewMatch     NotificationType = "new_match"
-	NotificationTypeNewMessage   NotificationType = "new_message"
-	NotificationTypeGiftReceived NotificationType = "gift_received"
-	NotificationTypeSomeoneLiked NotificationType = "someone_liked"
+	NotificationTypeNewMatch      NotificationType = "new_match"
+	NotificationTypeNewMessage    NotificationType = "new_message"
+	NotificationTypeGiftReceived  NotificationType = "gift_received"
+	NotificationTypeSomeoneLiked  NotificationType = "someone_liked"
+	NotificationTypeSuperLiked    NotificationType = "super_liked"
+	NotificationTypeMatchExpiring NotificationType = "match_expiring"
+	NotificationTypeBunaUpdate    NotificationType = "buna_update"
+	NotificationTypeBunaReminder  NotificationType = "buna_reminder"
+	NotificationTypeBunaFeedback  NotificationType = "buna_feedback"
 )
 
 // SendNotification sends a push notification
@@ -201,6 +206,124 @@ func (ns *NotificationService) NotifyNewMatch(match models.Match, matchedUser mo
 	return nil
 }
 
+// NotifyMatchExpiring reminds both users that their match expires soon unless someone writes
+func (ns *NotificationService) NotifyMatchExpiring(match models.Match) error {
+	var users []models.User
+	if err := database.DB.Where("id IN ?", []uuid.UUID{match.User1ID, match.User2ID}).Find(&users).Error; err != nil {
+		return err
+	}
+
+	hoursLeft := 0
+	if match.ExpiresAt != nil {
+		hoursLeft = int(time.Until(*match.ExpiresAt).Hours())
+	}
+
+	for _, u := range users {
+		// Name the other person in each user's notification
+		var other models.User
+		for _, candidate := range users {
+			if candidate.ID != u.ID {
+				other = candidate
+			}
+		}
+
+		title := "Your match is about to expire ⏳"
+		body := fmt.Sprintf("Say hi to %s — your match expires in %d hours", other.Name, hoursLeft)
+		data := map[string]interface{}{
+			"type":       string(NotificationTypeMatchExpiring),
+			"match_id":   match.ID.String(),
+			"user_id":    other.ID.String(),
+			"expires_at": match.ExpiresAt,
+		}
+
+		if err := ns.SendNotification(u.ID, NotificationTypeMatchExpiring, title, body, data); err != nil {
+			log.Printf("Failed to send match expiring notification: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// NotifyBunaUpdate tells the other participant that a buna invite was answered, moved or cancelled
+func (ns *NotificationService) NotifyBunaUpdate(message models.Message, invite BunaInvite, actorID uuid.UUID) error {
+	var actor models.User
+	if err := database.DB.Select("id", "name").First(&actor, "id = ?", actorID).Error; err != nil {
+		return err
+	}
+
+	recipientID := message.SenderID
+	if recipientID == actorID {
+		recipientID = message.ReceiverID
+	}
+
+	when := invite.ScheduledAt.In(bunaLocation).Format("Mon Jan 2, 15:04")
+	var title, body string
+	switch invite.Status {
+	case BunaStatusAccepted:
+		title = "Buna is on ☕"
+		body = fmt.Sprintf("%s accepted buna at %s, %s", actor.Name, invite.CafeName, when)
+	case BunaStatusDeclined:
+		title = "Buna declined"
+		body = fmt.Sprintf("%s can't make buna at %s", actor.Name, invite.CafeName)
+	case BunaStatusRescheduled:
+		title = "New time for buna ☕"
+		body = fmt.Sprintf("%s suggested %s at %s", actor.Name, when, invite.CafeName)
+	case BunaStatusCancelled:
+		title = "Buna cancelled"
+		body = fmt.Sprintf("%s cancelled buna at %s", actor.Name, invite.CafeName)
+	default:
+		return nil
+	}
+
+	data := map[string]interface{}{
+		"type":         string(NotificationTypeBunaUpdate),
+		"match_id":     message.MatchID.String(),
+		"message_id":   message.ID.String(),
+		"status":       string(invite.Status),
+		"scheduled_at": invite.ScheduledAt,
+	}
+
+	return ns.SendNotification(recipientID, NotificationTypeBunaUpdate, title, body, data)
+}
+
+// NotifyBunaReminder reminds both participants of an accepted buna coming up
+func (ns *NotificationService) NotifyBunaReminder(message models.Message, invite BunaInvite) error {
+	title := "Buna soon ☕"
+	body := fmt.Sprintf("Buna at %s, %s. Meet in public and tell a friend where you're going.",
+		invite.CafeName, invite.ScheduledAt.In(bunaLocation).Format("15:04"))
+	data := map[string]interface{}{
+		"type":         string(NotificationTypeBunaReminder),
+		"match_id":     message.MatchID.String(),
+		"message_id":   message.ID.String(),
+		"scheduled_at": invite.ScheduledAt,
+	}
+
+	for _, userID := range []uuid.UUID{message.SenderID, message.ReceiverID} {
+		if err := ns.SendNotification(userID, NotificationTypeBunaReminder, title, body, data); err != nil {
+			log.Printf("Failed to send buna reminder: %v", err)
+		}
+	}
+	return nil
+}
+
+// NotifyBunaFeedback asks both participants how their buna went
+func (ns *NotificationService) NotifyBunaFeedback(message models.Message, invite BunaInvite) error {
+	title := "How was buna? ☕"
+	body := fmt.Sprintf("Tell us how it went at %s. Your answer is private.", invite.CafeName)
+	data := map[string]interface{}{
+		"type":       string(NotificationTypeBunaFeedback),
+		"match_id":   message.MatchID.String(),
+		"message_id": message.ID.String(),
+	}
+
+	for _, userID := range []uuid.UUID{message.SenderID, message.ReceiverID} {
+		if err := ns.SendNotification(userID, NotificationTypeBunaFeedback, title, body, data); err != nil {
+			log.Printf("Failed to send buna feedback prompt: %v", err)
+		}
+	}
+	return nil
+}
+
 // NotifyNewMessage sends notification for a new message
 func (ns *NotificationService) NotifyNewMessage(message models.Message, sender models.User) error {
 	title := fmt.Sprintf("New message from %s", sender.Name)
@@ -216,6 +339,12 @@ func (ns *NotificationService) NotifyNewMessage(message models.Message, sender m
 		body = "📷 Photo"
 	} else if message.MessageType == models.MessageTypeVideo {
 		body = "🎥 Video"
+	} else if message.MessageType == models.MessageTypeVoice {
+		body = "🎤 Voice message"
+	} else if message.MessageType == models.MessageTypeBunaInvite {
+		body = "☕ Invited you for buna"
+	} else if message.MessageType == models.MessageTypePromptReply {
+		body = "💬 Replied to your prompt"
 	} else {
 		body = "New message"
 	}
@@ -244,18 +373,30 @@ func (ns *NotificationService) NotifyGiftReceived(giftTransaction models.GiftTra
 	return ns.SendNotification(giftTransaction.ReceiverID, NotificationTypeGiftReceived, title, body, data)
 }
 
-// NotifySomeoneLiked sends notification when someone likes you (but no match yet)
-func (ns *NotificationService) NotifySomeoneLiked(liker models.User, likedUserID uuid.UUID) error {
+// NotifySomeoneLiked sends notification when someone likes you (but no match yet).
+// The liker is not identified: seeing who it is costs a reveal.
+func (ns *NotificationService) NotifySomeoneLiked(likedUserID uuid.UUID) error {
 	title := "Someone liked you! 👀"
 	body := "Check out who's interested in you"
 	data := map[string]interface{}{
-		"type":     string(NotificationTypeSomeoneLiked),
-		"liker_id": liker.ID.String(),
+		"type": string(NotificationTypeSomeoneLiked),
 	}
 
 	return ns.SendNotification(likedUserID, NotificationTypeSomeoneLiked, title, body, data)
 }
 
+// NotifySuperLiked sends notification when someone super likes you. As with
+// NotifySomeoneLiked, the liker stays anonymous until revealed.
+func (ns *NotificationService) NotifySuperLiked(likedUserID uuid.UUID) error {
+	title := "⭐ You got a Super Like!"
+	body := "Someone really wants to meet you"
+	data := map[string]interface{}{
+		"type": string(NotificationTypeSuperLiked),
+	}
+
+	return ns.SendNotification(likedUserID, NotificationTypeSuperLiked, title, body, data)
+}
+
 // NotifySomeoneViewedProfile sends notification when someone spends coins to reveal your profile
 func (ns *NotificationService) NotifySomeoneViewedProfile(viewedUserID uuid.UUID, viewerID uuid.UUID) error {
 	var viewer models.User
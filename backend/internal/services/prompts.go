@@ -0,0 +1,225 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"lomi-backend/internal/database"
+	"lomi-backend/internal/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxProfilePrompts      = 3   // Answers a user can show on their profile
+	maxPromptAnswerLength  = 250 // Runes
+	maxPromptCommentLength = 300 // Runes of a reply sent with a like
+)
+
+// Errors returned by the prompt functions
+var (
+	ErrPromptNotFound       = errors.New("prompt not found")
+	ErrPromptAnswerScreened = errors.New("prompt answer blocked by the content filter")
+)
+
+// ProfilePromptAnswer is one answer as sent by the client
+type ProfilePromptAnswer struct {
+	PromptID uuid.UUID `json:"prompt_id"`
+	Answer   string    `json:"answer"`
+}
+
+// ActivePrompts returns the catalogue users can answer from
+func ActivePrompts() ([]models.Prompt, error) {
+	prompts := make([]models.Prompt, 0)
+	err := database.DB.Where("is_active = ?", true).Order("display_order ASC, created_at ASC").Find(&prompts).Error
+	return prompts, err
+}
+
+// ProfilePrompts returns the users' answers to active prompts, in profile order
+func ProfilePrompts(userIDs []uuid.UUID) (map[uuid.UUID][]models.ProfilePrompt, error) {
+	byUser := make(map[uuid.UUID][]models.ProfilePrompt, len(userIDs))
+	if len(userIDs) == 0 {
+		return byUser, nil
+	}
+
+	var answers []models.ProfilePrompt
+	if err := database.DB.
+		Joins("Prompt").
+		Where("profile_prompts.user_id IN ? AND \"Prompt\".is_active = ?", userIDs, true).
+		Order("profile_prompts.position ASC").
+		Find(&answers).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range answers {
+		byUser[a.UserID] = append(byUser[a.UserID], a)
+	}
+	return byUser, nil
+}
+
+// SetProfilePrompts replaces the user's answers with the given ones, in order. Answers to
+// prompts that stay keep their ID, so likes replying to them still resolve. Answers are
+// screened with the chat rules, but a profile is shown to strangers with no warning banner,
+// so any hit (contact details, links, payment handles) rejects the answer.
+func SetProfilePrompts(userID uuid.UUID, answers []ProfilePromptAnswer) ([]models.ProfilePrompt, error) {
+	if len(answers) > MaxProfilePrompts {
+		return nil, invalidMessage(fmt.Sprintf("at most %d prompts can be answered", MaxProfilePrompts))
+	}
+
+	promptIDs := make([]uuid.UUID, 0, len(answers))
+	seen := make(map[uuid.UUID]bool, len(answers))
+	for i := range answers {
+		a := &answers[i]
+		a.Answer = strings.TrimSpace(a.Answer)
+		if seen[a.PromptID] {
+			return nil, invalidMessage("each prompt can be answered once")
+		}
+		seen[a.PromptID] = true
+		if a.Answer == "" {
+			return nil, invalidMessage("answers cannot be empty")
+		}
+		if !utf8.ValidString(a.Answer) || utf8.RuneCountInString(a.Answer) > maxPromptAnswerLength {
+			return nil, invalidMessage(fmt.Sprintf("answers must be at most %d characters", maxPromptAnswerLength))
+		}
+		screening := ScreenMessage(ScreeningInput{SenderID: userID, MessageType: models.MessageTypeText, Content: a.Answer})
+		if screening.Action != models.ScreeningActionAllow {
+			return nil, ErrPromptAnswerScreened
+		}
+		promptIDs = append(promptIDs, a.PromptID)
+	}
+
+	if len(promptIDs) > 0 {
+		var active int64
+		if err := database.DB.Model(&models.Prompt{}).Where("id IN ? AND is_active = ?", promptIDs, true).
+			Count(&active).Error; err != nil {
+			return nil, err
+		}
+		if int(active) != len(promptIDs) {
+			return nil, ErrPromptNotFound
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		remove := tx.Where("user_id = ?", userID)
+		if len(promptIDs) > 0 {
+			remove = remove.Where("prompt_id NOT IN ?", promptIDs)
+		}
+		if err := remove.Delete(&models.ProfilePrompt{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, a := range answers {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "user_id"}, {Name: "prompt_id"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{
+					"answer":     a.Answer,
+					"position":   i,
+					"updated_at": now,
+				}),
+			}).Create(&models.ProfilePrompt{
+				UserID:   userID,
+				PromptID: a.PromptID,
+				Answer:   a.Answer,
+				Position: i,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prompts, err := ProfilePrompts([]uuid.UUID{userID})
+	if err != nil {
+		return nil, err
+	}
+	if prompts[userID] == nil {
+		return []models.ProfilePrompt{}, nil
+	}
+	return prompts[userID], nil
+}
+
+// loadProfilePrompt loads an answer of the given user to an active prompt
+func loadProfilePrompt(answerID, ownerID uuid.UUID) (*models.ProfilePrompt, error) {
+	var answer models.ProfilePrompt
+	if err := database.DB.Joins("Prompt").
+		Where("profile_prompts.id = ? AND profile_prompts.user_id = ? AND \"Prompt\".is_active = ?", answerID, ownerID, true).
+		First(&answer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptNotFound
+		}
+		return nil, err
+	}
+	return &answer, nil
+}
+
+// ValidatePromptLike checks a prompt reply sent with a like and returns the trimmed comment
+func ValidatePromptLike(swipedID, answerID uuid.UUID, comment string) (string, error) {
+	comment = strings.TrimSpace(comment)
+	if !utf8.ValidString(comment) || utf8.RuneCountInString(comment) > maxPromptCommentLength {
+		return "", invalidMessage(fmt.Sprintf("comment must be at most %d characters", maxPromptCommentLength))
+	}
+	if _, err := loadProfilePrompt(answerID, swipedID); err != nil {
+		return "", err
+	}
+	return comment, nil
+}
+
+// newPromptReplyMetadata quotes the receiver's answer in a prompt_reply message. The prompt
+// and answer are copied so the message reads the same after the answer changes.
+func newPromptReplyMetadata(receiverID uuid.UUID, raw map[string]interface{}) (models.JSONMap, error) {
+	idStr, _ := raw["prompt_answer_id"].(string)
+	answerID, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, invalidMessage("prompt_reply messages need a prompt_answer_id")
+	}
+	answer, err := loadProfilePrompt(answerID, receiverID)
+	if err != nil {
+		if errors.Is(err, ErrPromptNotFound) {
+			return nil, invalidMessage("prompt answer not found")
+		}
+		return nil, err
+	}
+
+	return models.JSONMap{
+		"prompt_answer_id": answer.ID.String(),
+		"prompt_id":        answer.PromptID.String(),
+		"prompt_text_en":   answer.Prompt.TextEN,
+		"prompt_text_am":   answer.Prompt.TextAM,
+		"answer":           answer.Answer,
+	}, nil
+}
+
+// SendPromptReplies opens a new match's chat with the prompt replies its likes carried,
+// oldest first. Each swipe sends at most once (the swipe ID is the client message ID).
+func SendPromptReplies(match models.Match) {
+	var swipes []models.Swipe
+	if err := database.DB.
+		Where("((swiper_id = ? AND swiped_id = ?) OR (swiper_id = ? AND swiped_id = ?)) AND prompt_answer_id IS NOT NULL",
+			match.User1ID, match.User2ID, match.User2ID, match.User1ID).
+		Order("created_at ASC").
+		Find(&swipes).Error; err != nil {
+		log.Printf("⚠️ Failed to load prompt replies for match %s: %v", match.ID, err)
+		return
+	}
+
+	for _, swipe := range swipes {
+		_, _, err := SendChatMessage(swipe.SwiperID, SendMessageInput{
+			MatchID:         match.ID,
+			MessageType:     models.MessageTypePromptReply,
+			Content:         swipe.PromptComment,
+			Metadata:        map[string]interface{}{"prompt_answer_id": swipe.PromptAnswerID.String()},
+			ClientMessageID: "swipe:" + swipe.ID.String(),
+		})
+		if err != nil {
+			log.Printf("⚠️ Failed to send prompt reply of swipe %s: %v", swipe.ID, err)
+		}
+	}
+}
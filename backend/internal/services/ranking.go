@@ -0,0 +1,230 @@
+package services
+
+import (
+	"lomi-backend/config"
+	"lomi-backend/internal/models"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RankingWeights controls how much each signal contributes to a candidate's score.
+// Every signal is normalised to 0..1 before weighting.
+type RankingWeights struct {
+	Distance         float64
+	SharedInterests  float64
+	SharedLanguages  float64
+	RelationshipGoal float64
+	Religion         float64
+	Activity         float64
+	Verified         float64
+	LikeRate         float64
+}
+
+// RankingWeightsFromConfig reads the weights from RANK_WEIGHT_* (defaults in config.LoadConfig)
+func RankingWeightsFromConfig(cfg *config.Config) RankingWeights {
+	return RankingWeights{
+		Distance:         cfg.RankWeightDistance,
+		SharedInterests:  cfg.RankWeightSharedInterests,
+		SharedLanguages:  cfg.RankWeightSharedLanguages,
+		RelationshipGoal: cfg.RankWeightRelationshipGoal,
+		Religion:         cfg.RankWeightReligion,
+		Activity:         cfg.RankWeightActivity,
+		Verified:         cfg.RankWeightVerified,
+		LikeRate:         cfg.RankWeightLikeRate,
+	}
+}
+
+// RankingViewer is the user the deck is being built for
+type RankingViewer struct {
+	User          models.User
+	MaxDistanceKm float64
+}
+
+// RankingCandidate is a discovery candidate plus the signals that are not on models.User
+type RankingCandidate struct {
+	User           models.User
+	DistanceKm     float64 // Negative when either side has no location
+	LikesReceived  int64   // Inbound like/super_like swipes
+	SwipesReceived int64   // All inbound swipes
+}
+
+// RankedCandidate is a candidate with its final score and per-signal breakdown
+type RankedCandidate struct {
+	RankingCandidate
+	Score   float64            `json:"score"`
+	Signals map[string]float64 `json:"signals"`
+}
+
+// Ranker orders discovery candidates for a viewer
+type Ranker interface {
+	Rank(viewer RankingViewer, candidates []RankingCandidate) []RankedCandidate
+}
+
+// WeightedRanker scores candidates as a weighted sum of normalised signals
+type WeightedRanker struct {
+	Weights RankingWeights
+	Now     func() time.Time
+}
+
+// DiscoveryRanker is the ranker used by GetSwipeCards, installed by InitRankingService
+var DiscoveryRanker Ranker
+
+// InitRankingService installs the discovery ranker with the configured weights
+func InitRankingService(weights RankingWeights) {
+	DiscoveryRanker = NewWeightedRanker(weights)
+}
+
+func NewWeightedRanker(weights RankingWeights) *WeightedRanker {
+	return &WeightedRanker{Weights: weights, Now: time.Now}
+}
+
+// Rank returns candidates sorted by score (highest first). Ties are broken by user ID
+// so the same input always produces the same order.
+func (r *WeightedRanker) Rank(viewer RankingViewer, candidates []RankingCandidate) []RankedCandidate {
+	now := r.Now()
+	ranked := make([]RankedCandidate, 0, len(candidates))
+
+	for _, cand := range candidates {
+		signals := map[string]float64{
+			"distance":          distanceSignal(cand.DistanceKm, viewer.MaxDistanceKm),
+			"shared_interests":  overlapSignal(viewer.User.Interests, cand.User.Interests),
+			"shared_languages":  overlapSignal(viewer.User.Languages, cand.User.Languages),
+			"relationship_goal": goalSignal(viewer.User.RelationshipGoal, cand.User.RelationshipGoal),
+			"religion":          religionSignal(viewer.User, cand.User.Religion),
+			"activity":          activitySignal(cand.User, now),
+			"verified":          boolSignal(cand.User.IsVerified),
+			"like_rate":         likeRateSignal(cand.LikesReceived, cand.SwipesReceived),
+		}
+
+		score := r.Weights.Distance*signals["distance"] +
+			r.Weights.SharedInterests*signals["shared_interests"] +
+			r.Weights.SharedLanguages*signals["shared_languages"] +
+			r.Weights.RelationshipGoal*signals["relationship_goal"] +
+			r.Weights.Religion*signals["religion"] +
+			r.Weights.Activity*signals["activity"] +
+			r.Weights.Verified*signals["verified"] +
+			r.Weights.LikeRate*signals["like_rate"]
+
+		ranked = append(ranked, RankedCandidate{
+			RankingCandidate: cand,
+			Score:            score,
+			Signals:          signals,
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].User.ID.String() < ranked[j].User.ID.String()
+	})
+
+	return ranked
+}
+
+// distanceSignal is 1 next door and falls linearly to 0 at the viewer's max distance
+func distanceSignal(distanceKm, maxDistanceKm float64) float64 {
+	if distanceKm < 0 || maxDistanceKm <= 0 {
+		return 0.25 // Unknown location: same city, distance unknown
+	}
+	return clamp01(1 - distanceKm/maxDistanceKm)
+}
+
+// overlapSignal is the Jaccard similarity of two tag lists (case-insensitive)
+func overlapSignal(a, b models.JSONStringArray) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	set := make(map[string]bool, len(a))
+	for _, v := range a {
+		set[strings.ToLower(v)] = true
+	}
+
+	shared := 0
+	union := len(set)
+	seen := make(map[string]bool, len(b))
+	for _, v := range b {
+		key := strings.ToLower(v)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if set[key] {
+			shared++
+		} else {
+			union++
+		}
+	}
+
+	return float64(shared) / float64(union)
+}
+
+// goalSignal rewards matching relationship goals; dating and serious are close
+func goalSignal(a, b models.RelationshipGoal) float64 {
+	if a == "" || b == "" {
+		return 0.5
+	}
+	if a == b {
+		return 1
+	}
+	if (a == models.GoalDating && b == models.GoalSerious) || (a == models.GoalSerious && b == models.GoalDating) {
+		return 0.5
+	}
+	return 0
+}
+
+// religionSignal honours the viewer's "religions" preference, falling back to same religion
+func religionSignal(viewer models.User, candidate models.Religion) float64 {
+	if prefs, ok := viewer.Preferences["religions"].([]interface{}); ok && len(prefs) > 0 {
+		for _, p := range prefs {
+			if s, ok := p.(string); ok && models.Religion(s) == candidate {
+				return 1
+			}
+		}
+		return 0
+	}
+
+	if viewer.Religion == "" || candidate == "" {
+		return 0.5
+	}
+	if viewer.Religion == candidate {
+		return 1
+	}
+	return 0
+}
+
+// activitySignal decays with time since the candidate was last seen (half-life 3 days)
+func activitySignal(u models.User, now time.Time) float64 {
+	if u.IsOnline {
+		return 1
+	}
+	if u.LastSeenAt.IsZero() {
+		return 0
+	}
+	hours := now.Sub(u.LastSeenAt).Hours()
+	if hours <= 0 {
+		return 1
+	}
+	return math.Exp2(-hours / 72)
+}
+
+// likeRateSignal is the share of inbound swipes that were likes, smoothed towards 25%
+// so that profiles with few swipes are neither buried nor promoted
+func likeRateSignal(likes, swipes int64) float64 {
+	const priorLikes, priorSwipes = 1.0, 4.0
+	return clamp01((float64(likes) + priorLikes) / (float64(swipes) + priorSwipes))
+}
+
+func boolSignal(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"lomi-backend/config"
+	"lomi-backend/internal/models"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// fixtureNow is the clock used when ranking the fixture
+var fixtureNow = time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+// rankingFixture is a fixed viewer and candidate set used to check ranking order
+func rankingFixture() (RankingViewer, []RankingCandidate) {
+	viewer := models.User{
+		ID:               uuid.MustParse("00000000-0000-0000-0000-000000000001"),
+		Name:             "Abel",
+		Gender:           models.GenderMale,
+		City:             "Addis Ababa",
+		RelationshipGoal: models.GoalSerious,
+		Religion:         models.ReligionOrthodox,
+		Languages:        models.JSONStringArray{"amharic", "english"},
+		Interests:        models.JSONStringArray{"buna_lover", "music", "travel", "football"},
+		Latitude:         9.0108,
+		Longitude:        38.7613,
+	}
+
+	candidate := func(id, name string, goal models.RelationshipGoal, religion models.Religion,
+		languages, interests []string, verified bool, lastSeen time.Duration,
+		distanceKm float64, likes, swipes int64) RankingCandidate {
+		return RankingCandidate{
+			User: models.User{
+				ID:               uuid.MustParse(id),
+				Name:             name,
+				Gender:           models.GenderFemale,
+				City:             "Addis Ababa",
+				RelationshipGoal: goal,
+				Religion:         religion,
+				Languages:        models.JSONStringArray(languages),
+				Interests:        models.JSONStringArray(interests),
+				IsVerified:       verified,
+				LastSeenAt:       fixtureNow.Add(-lastSeen),
+			},
+			DistanceKm:     distanceKm,
+			LikesReceived:  likes,
+			SwipesReceived: swipes,
+		}
+	}
+
+	return RankingViewer{User: viewer, MaxDistanceKm: 50}, []RankingCandidate{
+		// Far away, inactive for two weeks, nothing in common
+		candidate("00000000-0000-0000-0000-000000000010", "Tigist", models.GoalFriends, models.ReligionMuslim,
+			[]string{"oromo"}, []string{"fashion"}, false, 14*24*time.Hour, 45, 2, 40),
+		// Close, very active, shares most interests, same goal and religion
+		candidate("00000000-0000-0000-0000-000000000011", "Selam", models.GoalSerious, models.ReligionOrthodox,
+			[]string{"amharic", "english"}, []string{"buna_lover", "music", "travel"}, true, 10*time.Minute, 2, 30, 60),
+		// Close and compatible but offline for a few days
+		candidate("00000000-0000-0000-0000-000000000012", "Hana", models.GoalDating, models.ReligionOrthodox,
+			[]string{"amharic"}, []string{"music", "football"}, false, 3*24*time.Hour, 5, 10, 40),
+		// Same profile as Meron but unknown location; ranks just below her
+		candidate("00000000-0000-0000-0000-000000000013", "Bethlehem", models.GoalSerious, models.ReligionProtestant,
+			[]string{"amharic", "english"}, []string{"reading"}, true, 24*time.Hour, -1, 5, 20),
+		candidate("00000000-0000-0000-0000-000000000014", "Meron", models.GoalSerious, models.ReligionProtestant,
+			[]string{"amharic", "english"}, []string{"reading"}, true, 24*time.Hour, 20, 5, 20),
+	}
+}
+
+func TestWeightedRankerFixtureOrder(t *testing.T) {
+	// Ignore RANK_WEIGHT_* tuning in the environment: the expected order is for the defaults
+	for _, key := range []string{
+		"RANK_WEIGHT_DISTANCE", "RANK_WEIGHT_SHARED_INTERESTS", "RANK_WEIGHT_SHARED_LANGUAGES",
+		"RANK_WEIGHT_RELATIONSHIP_GOAL", "RANK_WEIGHT_RELIGION", "RANK_WEIGHT_ACTIVITY",
+		"RANK_WEIGHT_VERIFIED", "RANK_WEIGHT_LIKE_RATE",
+	} {
+		t.Setenv(key, "")
+	}
+
+	viewer, candidates := rankingFixture()
+	ranker := &WeightedRanker{
+		Weights: RankingWeightsFromConfig(config.LoadConfig()),
+		Now:     func() time.Time { return fixtureNow },
+	}
+	ranked := ranker.Rank(viewer, candidates)
+
+	expected := []string{"Selam", "Hana", "Meron", "Bethlehem", "Tigist"}
+	if len(ranked) != len(expected) {
+		t.Fatalf("ranked %d candidates, want %d", len(ranked), len(expected))
+	}
+	for i, r := range ranked {
+		if r.User.Name != expected[i] {
+			got := make([]string, len(ranked))
+			for j, r := range ranked {
+				got[j] = r.User.Name
+			}
+			t.Fatalf("order = %v, want %v", got, expected)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lomi-backend/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// A swipe deck is a per-user, pre-ranked queue of candidate IDs kept in Redis.
+// The queue is immutable for the lifetime of a session so offsets (cursors) stay
+// stable; cards already served or swiped are tracked in sets and skipped, so a card
+// is handed out at most once per session even when a cursor is replayed.
+//
+//	swipe_deck:{user_id}:session  current session ID
+//	swipe_deck:{user_id}:queue    ranked candidate IDs (list)
+//	swipe_deck:{user_id}:served   candidate IDs handed to the client (set)
+//	swipe_deck:{user_id}:swiped   candidate IDs already swiped this session (set)
+//	swipe_deck:{user_id}:priority candidate IDs to serve before the queue, e.g. after a rewind (list)
+//	swipe_deck:{user_id}:promoted candidate IDs served from the priority list (set)
+
+const SwipeDeckTTL = time.Hour
+
+var ErrSwipeDeckUnavailable = errors.New("swipe deck storage not available")
+
+// ErrSwipeDeckStale is returned when a cursor belongs to an expired or replaced session
+var ErrSwipeDeckStale = errors.New("swipe deck session expired")
+
+// SwipeDeckPage is one page of candidate IDs served from a deck
+type SwipeDeckPage struct {
+	SessionID    string
+	CandidateIDs []uuid.UUID
+	NextOffset   int
+	Remaining    int // Cards left in the queue after this page (including served or swiped ones)
+}
+
+// NextCursor returns the opaque cursor for the page after this one
+func (p *SwipeDeckPage) NextCursor() string {
+	return FormatSwipeDeckCursor(p.SessionID, p.NextOffset)
+}
+
+func swipeDeckKey(userID uuid.UUID, part string) string {
+	return fmt.Sprintf("swipe_deck:%s:%s", userID.String(), part)
+}
+
+// FormatSwipeDeckCursor encodes a session and offset as "<session>.<offset>"
+func FormatSwipeDeckCursor(sessionID string, offset int) string {
+	return fmt.Sprintf("%s.%d", sessionID, offset)
+}
+
+// ParseSwipeDeckCursor decodes a cursor produced by FormatSwipeDeckCursor
+func ParseSwipeDeckCursor(cursor string) (string, int, error) {
+	idx := strings.LastIndex(cursor, ".")
+	if idx <= 0 {
+		return "", 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(cursor[idx+1:])
+	if err != nil || offset < 0 {
+		return "", 0, fmt.Errorf("invalid cursor offset")
+	}
+	return cursor[:idx], offset, nil
+}
+
+// SwipeDeckSession returns the user's current deck session, or "" if there is none
+func SwipeDeckSession(userID uuid.UUID) (string, error) {
+	if database.RedisClient == nil {
+		return "", ErrSwipeDeckUnavailable
+	}
+
+	sessionID, err := database.RedisClient.Get(context.Background(), swipeDeckKey(userID, "session")).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return sessionID, err
+}
+
+// NewSwipeDeck replaces the user's deck with a freshly ranked queue and returns the new session ID
+func NewSwipeDeck(userID uuid.UUID, candidateIDs []uuid.UUID) (string, error) {
+	if database.RedisClient == nil {
+		return "", ErrSwipeDeckUnavailable
+	}
+
+	ctx := context.Background()
+	sessionID := uuid.New().String()
+
+	pipe := database.RedisClient.TxPipeline()
+	pipe.Del(ctx,
+		swipeDeckKey(userID, "queue"),
+		swipeDeckKey(userID, "served"),
+		swipeDeckKey(userID, "swiped"),
+		swipeDeckKey(userID, "promoted"),
+	)
+	if len(candidateIDs) > 0 {
+		values := make([]interface{}, len(candidateIDs))
+		for i, id := range candidateIDs {
+			values[i] = id.String()
+		}
+		pipe.RPush(ctx, swipeDeckKey(userID, "queue"), values...)
+		pipe.Expire(ctx, swipeDeckKey(userID, "queue"), SwipeDeckTTL)
+	}
+	pipe.Set(ctx, swipeDeckKey(userID, "session"), sessionID, SwipeDeckTTL)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", fmt.Errorf("failed to store swipe deck: %w", err)
+	}
+
+	return sessionID, nil
+}
+
+// NextSwipeDeckPage serves up to limit cards not yet served or swiped, starting at offset,
+// and marks them as served
+func NextSwipeDeckPage(userID uuid.UUID, sessionID string, offset, limit int) (*SwipeDeckPage, error) {
+	if database.RedisClient == nil {
+		return nil, ErrSwipeDeckUnavailable
+	}
+
+	current, err := SwipeDeckSession(userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == "" || current != sessionID {
+		return nil, ErrSwipeDeckStale
+	}
+
+	ctx := context.Background()
+	queueKey := swipeDeckKey(userID, "queue")
+
+	swiped, err := database.RedisClient.SUnion(ctx,
+		swipeDeckKey(userID, "served"),
+		swipeDeckKey(userID, "swiped"),
+		swipeDeckKey(userID, "promoted"),
+	).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load served cards: %w", err)
+	}
+	swipedSet := make(map[string]bool, len(swiped))
+	for _, id := range swiped {
+		swipedSet[id] = true
+	}
+
+	page := &SwipeDeckPage{SessionID: sessionID, CandidateIDs: make([]uuid.UUID, 0, limit), NextOffset: offset}
+
+	// Priority cards jump the queue; they do not move the cursor
+	promoted, err := database.RedisClient.LPopCount(ctx, swipeDeckKey(userID, "priority"), limit).Result()
+	if err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read swipe deck priority: %w", err)
+	}
+	for _, idStr := range promoted {
+		if swipedSet[idStr] {
+			continue
+		}
+		if id, err := uuid.Parse(idStr); err == nil {
+			page.CandidateIDs = append(page.CandidateIDs, id)
+			swipedSet[idStr] = true
+		}
+	}
+
+	total, err := database.RedisClient.LLen(ctx, queueKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read swipe deck: %w", err)
+	}
+
+	// Scan forward in chunks until the page is full, skipping cards served or swiped since the deck was built
+	for page.NextOffset < int(total) && len(page.CandidateIDs) < limit {
+		chunk, err := database.RedisClient.LRange(ctx, queueKey, int64(page.NextOffset), int64(page.NextOffset+limit-1)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read swipe deck: %w", err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		for _, idStr := range chunk {
+			page.NextOffset++
+			if swipedSet[idStr] {
+				continue
+			}
+			if id, err := uuid.Parse(idStr); err == nil {
+				page.CandidateIDs = append(page.CandidateIDs, id)
+				swipedSet[idStr] = true
+			}
+			if len(page.CandidateIDs) == limit {
+				break
+			}
+		}
+	}
+	page.Remaining = int(total) - page.NextOffset
+
+	pipe := database.RedisClient.TxPipeline()
+	if len(page.CandidateIDs) > 0 {
+		served := make([]interface{}, len(page.CandidateIDs))
+		for i, id := range page.CandidateIDs {
+			served[i] = id.String()
+		}
+		pipe.SAdd(ctx, swipeDeckKey(userID, "served"), served...)
+	}
+	if len(promoted) > 0 {
+		values := make([]interface{}, len(promoted))
+		for i, id := range promoted {
+			values[i] = id
+		}
+		pipe.SAdd(ctx, swipeDeckKey(userID, "promoted"), values...)
+	}
+	for _, part := range []string{"session", "queue", "served", "swiped", "promoted"} {
+		pipe.Expire(ctx, swipeDeckKey(userID, part), SwipeDeckTTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to update swipe deck: %w", err)
+	}
+
+	return page, nil
+}
+
+// PopSwipeDeckCard removes a swiped candidate from the user's deck so it is never served again
+func PopSwipeDeckCard(userID, candidateID uuid.UUID) error {
+	if database.RedisClient == nil {
+		return ErrSwipeDeckUnavailable
+	}
+
+	ctx := context.Background()
+	pipe := database.RedisClient.TxPipeline()
+	pipe.SAdd(ctx, swipeDeckKey(userID, "swiped"), candidateID.String())
+	pipe.Expire(ctx, swipeDeckKey(userID, "swiped"), SwipeDeckTTL)
+	pipe.SRem(ctx, swipeDeckKey(userID, "served"), candidateID.String())
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// PushSwipeDeckCard puts a candidate back at the top of the user's deck (used by rewind).
+// The card is served first on the next page request, whatever the cursor.
+func PushSwipeDeckCard(userID, candidateID uuid.UUID) error {
+	if database.RedisClient == nil {
+		return ErrSwipeDeckUnavailable
+	}
+
+	ctx := context.Background()
+	priorityKey := swipeDeckKey(userID, "priority")
+
+	pipe := database.RedisClient.TxPipeline()
+	pipe.SRem(ctx, swipeDeckKey(userID, "served"), candidateID.String())
+	pipe.SRem(ctx, swipeDeckKey(userID, "swiped"), candidateID.String())
+	pipe.SRem(ctx, swipeDeckKey(userID, "promoted"), candidateID.String())
+	pipe.LRem(ctx, priorityKey, 0, candidateID.String())
+	pipe.LPush(ctx, priorityKey, candidateID.String())
+	pipe.Expire(ctx, priorityKey, SwipeDeckTTL)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// PromoteSwipeDeckCards queues candidates to be served before the rest of the deck,
+// skipping any already served, swiped or queued this session
+func PromoteSwipeDeckCards(userID uuid.UUID, candidateIDs []uuid.UUID) error {
+	if database.RedisClient == nil {
+		return ErrSwipeDeckUnavailable
+	}
+	if len(candidateIDs) == 0 {
+		return nil
+	}
+
+	ctx := context.Background()
+	priorityKey := swipeDeckKey(userID, "priority")
+
+	seen, err := database.RedisClient.SUnion(ctx,
+		swipeDeckKey(userID, "served"),
+		swipeDeckKey(userID, "swiped"),
+		swipeDeckKey(userID, "promoted"),
+	).Result()
+	if err != nil {
+		return err
+	}
+	queued, err := database.RedisClient.LRange(ctx, priorityKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	skip := make(map[string]bool, len(seen)+len(queued))
+	for _, id := range append(seen, queued...) {
+		skip[id] = true
+	}
+
+	values := make([]interface{}, 0, len(candidateIDs))
+	for _, id := range candidateIDs {
+		if !skip[id.String()] {
+			values = append(values, id.String())
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	// Appended after any rewound cards, which stay on top
+	pipe := database.RedisClient.TxPipeline()
+	pipe.RPush(ctx, priorityKey, values...)
+	pipe.Expire(ctx, priorityKey, SwipeDeckTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
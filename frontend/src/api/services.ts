@@ -320,7 +320,7 @@ export const LikesService = {
 
     revealLike: async (data: {
         reveal_all: boolean;
-        target_id?: string;
+        like_id?: string;
     }) => {
         const response = await api.post('/likes/reveal', data);
         return response.data;
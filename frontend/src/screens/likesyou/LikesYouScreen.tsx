@@ -20,13 +20,17 @@ import { useAuthStore } from '../../store/authStore';
 
 const { width } = Dimensions.get('window');
 
+interface LikerSummary {
+    id: string;
+    name: string;
+    city: string;
+    avatar?: string;
+}
+
 interface PendingLike {
-    user: {
-        id: string;
-        name: string;
-        city: string;
-        avatar?: string;
-    };
+    like_id: string; // Handle for revealing; the liker's id only comes with user
+    user?: LikerSummary; // Revealed likers only
+    preview?: Omit<LikerSummary, 'id'> & { photo?: string }; // Unrevealed: initial, city and a tiny photo to blur
     liked_at: string;
     is_revealed: boolean;
 }
@@ -34,6 +38,7 @@ interface PendingLike {
 interface PendingLikesResponse {
     pending_likes: PendingLike[];
     count: number;
+    unrevealed_count: number;
     has_free_reveal: boolean;
     reset_at: string;
 }
@@ -75,7 +80,8 @@ const BlurCard = ({
         outputRange: [0.3, 0.7],
     });
 
-    const firstName = like.user.name.split(' ')[0];
+    const person = (like.user ?? like.preview)!;
+    const firstName = person.name.split(' ')[0];
     const maskedName = `${firstName[0]}***`;
 
     const timeAgo = (dateString: string) => {
@@ -108,12 +114,19 @@ const BlurCard = ({
                             ]}
                         >
                             <View style={styles.blurAvatar}>
+                                {like.preview?.photo ? (
+                                    <Image
+                                        source={{ uri: like.preview.photo }}
+                                        style={StyleSheet.absoluteFill}
+                                        blurRadius={20}
+                                    />
+                                ) : null}
                                 <View style={styles.blurOverlay} />
                                 <View style={styles.silhouetteGlow} />
                             </View>
                         </Animated.View>
                         <Text style={styles.blurName}>{maskedName}</Text>
-                        <Text style={styles.blurCity}>From {like.user.city}</Text>
+                        <Text style={styles.blurCity}>From {person.city}</Text>
                         <Text style={styles.blurTime}>Liked you {timeAgo(like.liked_at)}</Text>
                         <View style={styles.revealHint}>
                             <Text style={styles.revealHintText}>Tap to reveal 👆</Text>
@@ -122,9 +135,9 @@ const BlurCard = ({
                 ) : (
                     <>
                         {/* Revealed profile */}
-                        {like.user.avatar ? (
+                        {person.avatar ? (
                             <Image
-                                source={{ uri: like.user.avatar }}
+                                source={{ uri: person.avatar }}
                                 style={styles.revealedAvatar}
                             />
                         ) : (
@@ -134,8 +147,8 @@ const BlurCard = ({
                                 </Text>
                             </View>
                         )}
-                        <Text style={styles.revealedName}>{like.user.name}</Text>
-                        <Text style={styles.revealedCity}>From {like.user.city}</Text>
+                        <Text style={styles.revealedName}>{person.name}</Text>
+                        <Text style={styles.revealedCity}>From {person.city}</Text>
                         <Text style={styles.revealedTime}>Liked you {timeAgo(like.liked_at)}</Text>
                     </>
                 )}
@@ -148,6 +161,7 @@ export const LikesYouScreen = ({ navigation }: any) => {
     const { user } = useAuthStore();
     const [pendingLikes, setPendingLikes] = useState<PendingLike[]>([]);
     const [count, setCount] = useState(0);
+    const [unrevealedCount, setUnrevealedCount] = useState(0);
     const [hasFreeReveal, setHasFreeReveal] = useState(false);
     const [resetAt, setResetAt] = useState<Date | null>(null);
     const [isLoading, setIsLoading] = useState(true);
@@ -178,6 +192,7 @@ export const LikesYouScreen = ({ navigation }: any) => {
             const response: PendingLikesResponse = await LikesService.getPendingLikes();
             setPendingLikes(response.pending_likes || []);
             setCount(response.count || 0);
+            setUnrevealedCount(response.unrevealed_count || 0);
             setHasFreeReveal(response.has_free_reveal || false);
             if (response.reset_at) {
                 setResetAt(new Date(response.reset_at));
@@ -242,11 +257,14 @@ export const LikesYouScreen = ({ navigation }: any) => {
         setCountdown(`${hours}h ${minutes}m`);
     };
 
+    // Same price as the backend: never more than revealing each one for 99
+    const revealAllPrice = Math.min(299, Math.max(unrevealedCount, 1) * 99);
+
     const handleRevealAll = async () => {
         if (isRevealing) return;
 
         // Check if user has coins or free reveal
-        if (!hasFreeReveal && coinBalance < 299) {
+        if (!hasFreeReveal && coinBalance < revealAllPrice) {
             // Redirect to coin purchase
             navigation.navigate('BuyCoins', { 
                 preselectedPackage: 500,
@@ -259,17 +277,18 @@ export const LikesYouScreen = ({ navigation }: any) => {
         try {
             const response = await LikesService.revealLike({ reveal_all: true });
             
-            if (response.revealed_users && response.revealed_users.length > 0) {
+            if (response.revealed_likes && response.revealed_likes.length > 0) {
                 setShowConfetti(true);
                 setTimeout(() => setShowConfetti(false), 3000);
                 
                 // Update local state
                 const updatedLikes = pendingLikes.map(like => {
-                    const revealed = response.revealed_users.find((u: any) => u.id === like.user.id);
-                    return revealed ? { ...like, is_revealed: true, user: revealed } : like;
+                    const revealed = response.revealed_likes.find((r: any) => r.like_id === like.like_id);
+                    return revealed ? { ...like, is_revealed: true, user: revealed.user } : like;
                 });
                 setPendingLikes(updatedLikes);
                 setCount(0);
+                setUnrevealedCount(0);
                 setHasFreeReveal(false);
                 setCoinBalance(response.new_balance || 0);
 
@@ -295,7 +314,7 @@ export const LikesYouScreen = ({ navigation }: any) => {
         }
     };
 
-    const handleRevealOne = async (targetId: string) => {
+    const handleRevealOne = async (likeId: string) => {
         if (isRevealing) return;
 
         // Check if user has coins or free reveal
@@ -311,20 +330,21 @@ export const LikesYouScreen = ({ navigation }: any) => {
         try {
             const response = await LikesService.revealLike({ 
                 reveal_all: false,
-                target_id: targetId 
+                like_id: likeId 
             });
             
-            if (response.revealed_users && response.revealed_users.length > 0) {
-                const revealedUser = response.revealed_users[0];
+            if (response.revealed_likes && response.revealed_likes.length > 0) {
+                const revealed = response.revealed_likes[0];
                 
                 // Update local state
                 const updatedLikes = pendingLikes.map(like => 
-                    like.user.id === targetId 
-                        ? { ...like, is_revealed: true, user: revealedUser }
+                    like.like_id === revealed.like_id 
+                        ? { ...like, is_revealed: true, user: revealed.user }
                         : like
                 );
                 setPendingLikes(updatedLikes);
                 setCount(count - 1);
+                setUnrevealedCount(Math.max(unrevealedCount - 1, 0));
                 setHasFreeReveal(false);
                 setCoinBalance(response.new_balance || 0);
             }
@@ -448,8 +468,8 @@ export const LikesYouScreen = ({ navigation }: any) => {
                         <>
                             <Text style={styles.revealAllButtonText}>
                                 {language === 'am'
-                                    ? `Reveal all ${count} for ${hasFreeReveal ? 'FREE' : '299 coins'}`
-                                    : `Reveal all ${count} for ${hasFreeReveal ? 'FREE' : '299 coins'}`}
+                                    ? `Reveal all ${count} for ${hasFreeReveal ? 'FREE' : `${revealAllPrice} coins`}`
+                                    : `Reveal all ${count} for ${hasFreeReveal ? 'FREE' : `${revealAllPrice} coins`}`}
                             </Text>
                             {!hasFreeReveal && (
                                 <Text style={styles.revealAllButtonSubtext}>
@@ -465,7 +485,7 @@ export const LikesYouScreen = ({ navigation }: any) => {
                     style={styles.revealOneButton}
                     onPress={() => {
                         if (pendingLikes.length > 0 && !pendingLikes[0].is_revealed) {
-                            handleRevealOne(pendingLikes[0].user.id);
+                            handleRevealOne(pendingLikes[0].like_id);
                         }
                     }}
                     disabled={isRevealing || (pendingLikes.length > 0 && pendingLikes[0].is_revealed)}
@@ -481,10 +501,10 @@ export const LikesYouScreen = ({ navigation }: any) => {
                 <View style={styles.likesGrid}>
                     {pendingLikes.map((like, index) => (
                         <BlurCard
-                            key={like.user.id}
+                            key={like.like_id}
                             like={like}
                             isRevealed={like.is_revealed}
-                            onReveal={() => handleRevealOne(like.user.id)}
+                            onReveal={() => handleRevealOne(like.like_id)}
                         />
                     ))}
                 </View>